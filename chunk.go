@@ -0,0 +1,163 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+func (chunk *Chunk) EncodeSMF(w io.Writer) error {
+	if int64(len(chunk.Data)) > 0xffffffff {
+		return newSMFEncodeError(chunk, errors.New("chunk too long"))
+	}
+	var buf [8]byte
+	copy(buf[:4], chunk.Type[:])
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(chunk.Data)))
+	_, err := w.Write(buf[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(chunk.Data)
+	return err
+}
+
+func (chunk *Chunk) EncodeXML() *etree.Element {
+	if header := decodeExactXFHeader(chunk); header != nil {
+		el := etree.NewElement(header.xfTag())
+		el.CreateAttr("pos", fmt.Sprintf("%#x", chunk.FilePosition))
+		encodeXFHeaderXMLAttr(el, header)
+		return el
+	}
+	el := etree.NewElement("Chunk")
+	el.CreateAttr("pos", fmt.Sprintf("%#x", chunk.FilePosition))
+	el.CreateAttr("type", string(chunk.Type[:]))
+	el.SetText(fmt.Sprintf("% x", chunk.Data))
+	return el
+}
+
+// Decodes the rest of a chunk whose header has been read
+func decodeChunkFromSMF(r io.Reader, pos int64, header [8]byte, warningCallback WarningCallback) (chunk *Chunk, err error) {
+	chunk = &Chunk{
+		FilePosition: pos,
+	}
+	copy(chunk.Type[:], header[:4])
+	length := binary.BigEndian.Uint32(header[4:8])
+	chunk.Data, err = ioutil.ReadAll(io.LimitReader(r, int64(length)))
+	if err == nil && uint32(len(chunk.Data)) != length {
+		warningCallback(newSMFDecodeError(pos, fmt.Errorf("%q chunk is incomplete", chunk.Type[:])))
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// Splits the bytes after the last track into complete chunks, and whatever
+// does not look like one
+func decodeTrailingChunks(data []byte, pos int64, trackIndex int) (chunks []*Chunk, undecoded []byte) {
+	for len(data) >= 8 && isChunkType(data[:4]) {
+		length := binary.BigEndian.Uint32(data[4:8])
+		if uint64(length) > uint64(len(data)-8) {
+			break
+		}
+		chunk := &Chunk{
+			FilePosition: pos,
+			Data:         data[8 : 8+length],
+			TrackIndex:   trackIndex,
+		}
+		copy(chunk.Type[:], data[:4])
+		chunks = append(chunks, chunk)
+		data = data[8+length:]
+		pos += 8 + int64(length)
+	}
+	if len(data) != 0 {
+		undecoded = data
+	}
+	return
+}
+
+// Returns the number of bytes until the end of file, if r is able to tell
+func remainingLength(r io.ReadSeeker) (int64, bool) {
+	pos := tell(r)
+	if pos < 0 {
+		return 0, false
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+	_, err = r.Seek(pos, io.SeekStart)
+	if err != nil {
+		return 0, false
+	}
+	return end - pos, true
+}
+
+// Chunk types are four printable ASCII characters
+func isChunkType(typ []byte) bool {
+	for _, c := range typ {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func DecodeChunkFromXML(el *etree.Element) (*Chunk, error) {
+	if el.Tag != "Chunk" && el.Tag != "XFInfoHeader" && el.Tag != "XFKaraokeHeader" {
+		return nil, newXMLDecodeError(el, fmt.Errorf("expect a <Chunk> tag, but got <%s>", el.Tag))
+	}
+	pos, err := strconv.ParseInt(el.SelectAttrValue("pos", "0"), 0, 64)
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: pos=%q", el.Tag, el.SelectAttrValue("pos", "")))
+	}
+	if el.Tag != "Chunk" {
+		chunk, err := decodeXFHeaderFromXML(el)
+		if err != nil {
+			return nil, err
+		}
+		chunk.FilePosition = pos
+		return chunk, nil
+	}
+	typ := el.SelectAttrValue("type", "")
+	if len(typ) != 4 || !isChunkType([]byte(typ)) {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for Chunk tag: type=%q", typ))
+	}
+	data, err := parseHexDump(el.Text())
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("unable to decode tag <Chunk>"))
+	}
+	chunk := &Chunk{
+		FilePosition: pos,
+		Data:         data,
+	}
+	copy(chunk.Type[:], typ)
+	return chunk, nil
+}
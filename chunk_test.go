@@ -0,0 +1,142 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func buildTestSMF(parts ...[]byte) []byte {
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 2, 0, 96}
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+var testMTrk = []byte{'M', 'T', 'r', 'k', 0, 0, 0, 4, 0x00, 0xff, 0x2f, 0x00}
+
+func buildTestMTrk(data ...byte) []byte {
+	return append([]byte{'M', 'T', 'r', 'k', 0, 0, byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+func TestDecodeChunkGarbage(t *testing.T) {
+	// Printable garbage with a length running far past the end of file
+	garbage := []byte{'z', 'z', 'M', 'T', 0x7f, 0xff, 0xff, 0xff}
+	data := buildTestSMF(testMTrk, garbage, testMTrk)
+	var warnings []error
+	warn := func(err error) {
+		warnings = append(warnings, err)
+	}
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seq.Tracks) != 2 || len(seq.Chunks) != 0 {
+		t.Errorf("got %d tracks and %d chunks, want 2 tracks and no chunks", len(seq.Tracks), len(seq.Chunks))
+	}
+	if len(warnings) == 0 {
+		t.Errorf("garbage before MTrk was not reported")
+	}
+
+	// A stream can not tell the length is bogus, the rest of the input is kept in the chunk
+	warnings = nil
+	seq, err = DecodeSequenceFromStream(bytes.NewReader(data), warn)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("stream: got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if len(seq.Tracks) != 1 || len(seq.Chunks) != 1 || !bytes.Equal(seq.Chunks[0].Data, testMTrk) {
+		t.Errorf("stream: got %d tracks and chunks %+v, want 1 track and the second MTrk kept in a chunk", len(seq.Tracks), seq.Chunks)
+	}
+	if len(warnings) == 0 {
+		t.Errorf("stream: incomplete chunk was not reported")
+	}
+}
+
+func TestDecodeChunkInStream(t *testing.T) {
+	chunk := []byte{'A', 'B', 'C', 'D', 0, 0, 0, 2, 1, 2}
+	data := buildTestSMF(testMTrk, chunk, testMTrk)
+	seq, err := DecodeSequenceFromStream(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seq.Tracks) != 2 || len(seq.Chunks) != 1 {
+		t.Fatalf("got %d tracks and %d chunks, want 2 tracks and 1 chunk", len(seq.Tracks), len(seq.Chunks))
+	}
+	got := seq.Chunks[0]
+	if string(got.Type[:]) != "ABCD" || got.FilePosition != 26 || got.TrackIndex != 1 || !bytes.Equal(got.Data, []byte{1, 2}) {
+		t.Errorf("got chunk %+v", got)
+	}
+}
+
+func TestSMFReaderTrailingChunks(t *testing.T) {
+	chunk := func(typ string, data ...byte) []byte {
+		return append([]byte{typ[0], typ[1], typ[2], typ[3], 0, 0, 0, byte(len(data))}, data...)
+	}
+	data := buildTestSMF(testMTrk, chunk("ABCD", 1, 2), testMTrk, chunk("XTRA", 3))
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := NewSMFReader(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, _, err = sr.Next()
+		if err != nil {
+			break
+		}
+	}
+	chunks := sr.Chunks()
+	if len(chunks) != 2 || len(seq.Chunks) != 2 {
+		t.Fatalf("SMFReader found %d chunks and DecodeSequenceFromSMF found %d, want 2", len(chunks), len(seq.Chunks))
+	}
+	for i, chunk := range chunks {
+		want := seq.Chunks[i]
+		if chunk.Type != want.Type || chunk.FilePosition != want.FilePosition || chunk.TrackIndex != want.TrackIndex || !bytes.Equal(chunk.Data, want.Data) {
+			t.Errorf("chunk %d: SMFReader found %+v, DecodeSequenceFromSMF found %+v", i, chunk, want)
+		}
+	}
+}
+
+func TestDecodeMTrkSkippedChunk(t *testing.T) {
+	data := append([]byte{'A', 'B', 'C', 'D', 0, 0, 0, 2, 1, 2}, testMTrk...)
+	var warnings []error
+	mtrk, err := DecodeMTrkFromSMF(bytes.NewReader(data), func(err error) {
+		warnings = append(warnings, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtrk.FilePosition != 10 || len(mtrk.Events) != 1 {
+		t.Errorf("got %+v", mtrk)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("got warnings %v, want the skipped chunk reported", warnings)
+	}
+}
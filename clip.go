@@ -0,0 +1,715 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+var ErrInvalidClipHeader = errors.New("midimark: not a MIDI Clip File, \"SMF2CLIP\" header not found")
+
+// Status of Stream messages marking the clip sequence
+const (
+	UMPStartOfClip uint16 = 0x20
+	UMPEndOfClip   uint16 = 0x21
+)
+
+var clipFileHeader = []byte("SMF2CLIP")
+
+// Clip is a MIDI Clip File, a timed list of Universal MIDI Packets.
+// Delta Clockstamps are folded into the timing of the packet they precede, and
+// the Start of Clip message is implied between Header and Events. Events
+// normally ends with an End of Clip message.
+// NOOP messages are discarded when decoding, so a file containing them, or
+// splitting delta times differently, does not encode back to the same bytes.
+type Clip struct {
+	TicksPerQuarter uint16
+	Header          []*ClipEvent
+	Events          []*ClipEvent
+	Undecoded       []byte
+}
+
+// AbsTick of header events counts from the start of the header, and AbsTick of
+// sequence events from the Start of Clip message.
+type ClipEvent struct {
+	FilePosition int64
+	AbsTick      int64
+	DeltaTick    uint32
+	Packet       UMP
+}
+
+func DecodeClip(r io.Reader, warningCallback WarningCallback) (*Clip, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, clipFileHeader) {
+		return nil, newSMFDecodeError(0, ErrInvalidClipHeader)
+	}
+	clip := &Clip{}
+	br := bytes.NewReader(data[len(clipFileHeader):])
+	pos := func() int64 {
+		return int64(len(data)) - int64(br.Len())
+	}
+	inSequence, hasTPQ, hasEnd := false, false, false
+	delta, tick := uint64(0), int64(0)
+	for !hasEnd && br.Len() != 0 {
+		packetPos := pos()
+		p, err := DecodeUMP(br)
+		if err != nil {
+			warningCallback(newSMFDecodeError(packetPos, errors.New("incomplete Universal MIDI Packet")))
+			_, err = br.Seek(packetPos-int64(len(clipFileHeader)), io.SeekStart)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		switch p.MessageType() {
+		case UMPTypeUtility:
+			switch uint8(p[0]>>20) & 0xf {
+			case UMPNoop:
+				continue
+			case UMPDeltaClockstampTPQ:
+				if hasTPQ {
+					warningCallback(newSMFDecodeError(packetPos, errors.New("duplicate Delta Clockstamp Ticks Per Quarter Note message")))
+				}
+				clip.TicksPerQuarter = uint16(p[0])
+				hasTPQ = true
+				continue
+			case UMPDeltaClockstamp:
+				delta += uint64(p[0] & 0xfffff)
+				continue
+			}
+		case UMPTypeStream:
+			switch uint16(p[0]>>16) & 0x3ff {
+			case UMPStartOfClip:
+				if inSequence {
+					warningCallback(newSMFDecodeError(packetPos, errors.New("duplicate Start of Clip message")))
+				}
+				inSequence = true
+				delta, tick = 0, 0
+				continue
+			case UMPEndOfClip:
+				if !inSequence {
+					warningCallback(newSMFDecodeError(packetPos, errors.New("End of Clip message before Start of Clip")))
+				}
+				hasEnd = true
+			}
+		}
+		if delta > 0xffffffff {
+			warningCallback(newSMFDecodeError(packetPos, fmt.Errorf("delta time %d too long", delta)))
+			delta = 0xffffffff
+		}
+		tick += int64(delta)
+		ev := &ClipEvent{
+			FilePosition: packetPos,
+			AbsTick:      tick,
+			DeltaTick:    uint32(delta),
+			Packet:       p,
+		}
+		delta = 0
+		if inSequence {
+			clip.Events = append(clip.Events, ev)
+		} else {
+			clip.Header = append(clip.Header, ev)
+		}
+	}
+	if !hasTPQ {
+		warningCallback(newSMFDecodeError(int64(len(clipFileHeader)), errors.New("missing Delta Clockstamp Ticks Per Quarter Note message")))
+	}
+	if !inSequence {
+		warningCallback(newSMFDecodeError(pos(), errors.New("missing Start of Clip message")))
+	} else if !hasEnd {
+		warningCallback(newSMFDecodeError(pos(), errors.New("missing End of Clip message")))
+	}
+	if br.Len() != 0 {
+		clip.Undecoded = data[pos():]
+	}
+	return clip, nil
+}
+
+// Writes the clip file. An End of Clip message is added if Events does not end
+// with one.
+func (clip *Clip) EncodeClip(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.Write(clipFileHeader)
+	writeClipDelta(&buf, 0)
+	buf.Write((&UMPUtility{Status: UMPDeltaClockstampTPQ, Data: uint32(clip.TicksPerQuarter)}).UMP().EncodeBytes())
+	for _, ev := range clip.Header {
+		err := ev.encodeClip(&buf)
+		if err != nil {
+			return err
+		}
+	}
+	writeClipDelta(&buf, 0)
+	buf.Write((&UMPStream{Status: UMPStartOfClip}).UMP().EncodeBytes())
+	hasEnd := false
+	for _, ev := range clip.Events {
+		err := ev.encodeClip(&buf)
+		if err != nil {
+			return err
+		}
+		hasEnd = ev.isEndOfClip()
+	}
+	if !hasEnd {
+		writeClipDelta(&buf, 0)
+		buf.Write((&UMPStream{Status: UMPEndOfClip}).UMP().EncodeBytes())
+	}
+	buf.Write(clip.Undecoded)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (ev *ClipEvent) encodeClip(buf *bytes.Buffer) error {
+	if len(ev.Packet) == 0 || len(ev.Packet) != UMPWordCount(ev.Packet.MessageType()) {
+		return newSMFEncodeError(ev, fmt.Errorf("invalid Universal MIDI Packet %s", ev.Packet))
+	}
+	writeClipDelta(buf, ev.DeltaTick)
+	buf.Write(ev.Packet.EncodeBytes())
+	return nil
+}
+
+// Delta times longer than 20 bits are split into several Delta Clockstamps
+func writeClipDelta(buf *bytes.Buffer, delta uint32) {
+	for {
+		chunk := delta
+		if chunk > 0xfffff {
+			chunk = 0xfffff
+		}
+		buf.Write((&UMPUtility{Status: UMPDeltaClockstamp, Data: chunk}).UMP().EncodeBytes())
+		delta -= chunk
+		if delta == 0 {
+			return
+		}
+	}
+}
+
+func (ev *ClipEvent) isEndOfClip() bool {
+	return ev.Packet.MessageType() == UMPTypeStream && len(ev.Packet) != 0 && uint16(ev.Packet[0]>>16)&0x3ff == UMPEndOfClip
+}
+
+// Recalculates DeltaTick of each event from AbsTick
+func (clip *Clip) ConvertAbsToDeltaTick() error {
+	for _, events := range [][]*ClipEvent{clip.Header, clip.Events} {
+		lastTick := int64(0)
+		for _, ev := range events {
+			if ev.AbsTick < lastTick {
+				return newEditError(ev, ErrEventsNotSorted)
+			}
+			if ev.AbsTick-lastTick > 0xffffffff {
+				return newEditError(ev, fmt.Errorf("delta time %d too long", ev.AbsTick-lastTick))
+			}
+			ev.DeltaTick = uint32(ev.AbsTick - lastTick)
+			lastTick = ev.AbsTick
+		}
+	}
+	return nil
+}
+
+func (clip *Clip) EncodeXML() *etree.Element {
+	el := etree.NewElement("Clip")
+	el.CreateAttr("ticks-per-quarter", fmt.Sprintf("%d", clip.TicksPerQuarter))
+	header := etree.NewElement("ClipHeader")
+	for _, ev := range clip.Header {
+		header.AddChild(ev.EncodeXML())
+	}
+	el.AddChild(header)
+	sequence := etree.NewElement("ClipSequence")
+	for _, ev := range clip.Events {
+		sequence.AddChild(ev.EncodeXML())
+	}
+	el.AddChild(sequence)
+	if len(clip.Undecoded) != 0 {
+		undecoded := etree.NewElement("Undecoded")
+		undecoded.SetText(fmt.Sprintf("% x", clip.Undecoded))
+		el.AddChild(undecoded)
+	}
+	return el
+}
+
+func (clip *Clip) EncodeXMLToDocument(w io.Writer) (n int64, err error) {
+	doc := etree.NewDocument()
+	doc.AddChild(clip.EncodeXML())
+	doc.Indent(2)
+	return doc.WriteTo(w)
+}
+
+// MIDI 1.0 Channel Voice and System packets are written as the event tags of
+// a MIDI track, with an additional group attribute. Other packets are written
+// as UMP tags holding the words in hexadecimal.
+func (ev *ClipEvent) EncodeXML() *etree.Element {
+	if event := ev.decodeEvent(); event != nil && ev.DeltaTick < 0x10000000 {
+		evCommon := event.Common()
+		evCommon.FilePosition = ev.FilePosition
+		evCommon.AbsTick = ev.AbsTick
+		evCommon.DeltaTick = VLQ(ev.DeltaTick)
+		el := event.EncodeXML()
+		if group := ev.Packet.Group(); group != 0 {
+			el.CreateAttr("group", fmt.Sprintf("%d", group))
+		}
+		return el
+	}
+	el := etree.NewElement("UMP")
+	el.CreateAttr("pos", fmt.Sprintf("%#x", ev.FilePosition))
+	el.CreateAttr("tick", fmt.Sprintf("%d", ev.AbsTick))
+	el.CreateAttr("delta", fmt.Sprintf("%d", ev.DeltaTick))
+	el.SetText(ev.Packet.String())
+	return el
+}
+
+// Returns the event of a MIDI 1.0 Channel Voice or System packet, or nil if
+// the event does not encode back to the same packet
+func (ev *ClipEvent) decodeEvent() Event {
+	if messageType := ev.Packet.MessageType(); messageType != UMPTypeSystem && messageType != UMPTypeMIDI1ChannelVoice {
+		return nil
+	}
+	events, err := NewUMPDecoder(IgnoreWarnings).Decode(ev.Packet)
+	if err != nil || len(events) != 1 {
+		return nil
+	}
+	packets, err := EventToUMP(events[0], ev.Packet.Group())
+	if err != nil || len(packets) != 1 || packets[0].String() != ev.Packet.String() {
+		return nil
+	}
+	return events[0]
+}
+
+func DecodeClipFromXML(el *etree.Element) (*Clip, error) {
+	if el.Tag != "Clip" {
+		return nil, newXMLDecodeError(el, fmt.Errorf("expect a <Clip> tag, but got <%s>", el.Tag))
+	}
+	tpq, err := strconv.ParseUint(el.SelectAttrValue("ticks-per-quarter", ""), 0, 16)
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for Clip tag: ticks-per-quarter=%q", el.SelectAttrValue("ticks-per-quarter", "")))
+	}
+	clip := &Clip{
+		TicksPerQuarter: uint16(tpq),
+	}
+	for _, child := range el.ChildElements() {
+		switch child.Tag {
+		case "ClipHeader", "ClipSequence":
+			var events []*ClipEvent
+			for _, eventEl := range child.ChildElements() {
+				ev, err := DecodeClipEventFromXML(eventEl)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, ev)
+			}
+			if child.Tag == "ClipHeader" {
+				clip.Header = append(clip.Header, events...)
+			} else {
+				clip.Events = append(clip.Events, events...)
+			}
+		case "Undecoded":
+			clip.Undecoded, err = parseHexDump(child.Text())
+			if err != nil {
+				return nil, newXMLDecodeError(child, fmt.Errorf("unable to decode tag <Undecoded>"))
+			}
+		default:
+			return nil, newXMLDecodeError(child, fmt.Errorf("unexpected tag <%s>", child.Tag))
+		}
+	}
+	return clip, nil
+}
+
+func DecodeClipEventFromXML(el *etree.Element) (*ClipEvent, error) {
+	if el.Tag != "UMP" {
+		group, err := strconv.ParseUint(el.SelectAttrValue("group", "0"), 0, 4)
+		if err != nil {
+			return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for event tag: group=%q", el.SelectAttrValue("group", "")))
+		}
+		event, err := DecodeEventFromXML(el)
+		if err != nil {
+			return nil, err
+		}
+		packets, err := EventToUMP(event, uint8(group))
+		if err == nil && len(packets) != 1 {
+			err = ErrUMPUnsupportedEvent
+		}
+		if err != nil {
+			return nil, newXMLDecodeError(el, fmt.Errorf("<%s> tag can not be represented as a single Universal MIDI Packet", el.Tag))
+		}
+		evCommon := event.Common()
+		return &ClipEvent{
+			FilePosition: evCommon.FilePosition,
+			AbsTick:      evCommon.AbsTick,
+			DeltaTick:    uint32(evCommon.DeltaTick),
+			Packet:       packets[0],
+		}, nil
+	}
+	pos, err := strconv.ParseInt(el.SelectAttrValue("pos", "0"), 0, 64)
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for UMP tag: pos=%q", el.SelectAttrValue("pos", "")))
+	}
+	tick, err := strconv.ParseInt(el.SelectAttrValue("tick", "0"), 0, 64)
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for UMP tag: tick=%q", el.SelectAttrValue("tick", "")))
+	}
+	delta, err := strconv.ParseUint(el.SelectAttrValue("delta", "0"), 0, 32)
+	if err != nil {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for UMP tag: delta=%q", el.SelectAttrValue("delta", "")))
+	}
+	var packet UMP
+	for _, field := range strings.Fields(el.Text()) {
+		word, err := strconv.ParseUint(field, 16, 32)
+		if err != nil {
+			return nil, newXMLDecodeError(el, fmt.Errorf("unable to decode tag <UMP>"))
+		}
+		packet = append(packet, uint32(word))
+	}
+	if len(packet) == 0 || len(packet) != UMPWordCount(packet.MessageType()) {
+		return nil, newXMLDecodeError(el, fmt.Errorf("invalid Universal MIDI Packet %s", packet))
+	}
+	return &ClipEvent{
+		FilePosition: pos,
+		AbsTick:      tick,
+		DeltaTick:    uint32(delta),
+		Packet:       packet,
+	}, nil
+}
+
+func DecodeClipFromDocument(r io.Reader) (clip *Clip, n int64, err error) {
+	doc := etree.NewDocument()
+	doc.ReadSettings.Permissive = true
+	n, err = doc.ReadFrom(r)
+	if err != nil {
+		return nil, n, newXMLDecodeError(&doc.Element, err)
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, n, newXMLDecodeError(&doc.Element, errors.New("XML file contains no root tag"))
+	}
+	clip, err = DecodeClipFromXML(root)
+	return
+}
+
+// Converts the sequence into a clip, following the translation rules of the
+// MIDI Clip File specification. All events go to group 0, and channel
+// messages are translated to MIDI 2.0 if midi2 is true.
+// Set Tempo, Time Signature, Key Signature and text meta events become Flex
+// Data messages, other meta events are dropped with a warning.
+func (seq *Sequence) ConvertToClip(midi2 bool, warningCallback WarningCallback) (*Clip, error) {
+	if seq.Header.Format == 2 {
+		return nil, ErrFormat2Conversion
+	}
+	if seq.Header.Framerate != 0 || seq.Header.Division == 0 || seq.Header.Division >= 0x8000 {
+		return nil, newEditError(seq.Header, ErrInvalidDivision)
+	}
+	clip := &Clip{
+		TicksPerQuarter: seq.Header.Division,
+	}
+	translator := NewUMPTranslator()
+	endTick := int64(0)
+	it := newEventIterator(seq)
+	for {
+		_, event, absTick, ok := it.next()
+		if !ok {
+			break
+		}
+		if absTick > endTick {
+			endTick = absTick
+		}
+		var packets []UMP
+		switch ev := event.(type) {
+		case *MetaEventEndOfTrack:
+			continue
+		case MetaEvent:
+			packets = metaEventToFlexData(ev)
+			if packets == nil {
+				warningCallback(newEditError(event, fmt.Errorf("meta event %T at tick %d has no Universal MIDI Packet form, dropped", event, absTick)))
+				continue
+			}
+		default:
+			var err error
+			packets, err = EventToUMP(event, 0)
+			if err != nil {
+				warningCallback(newEditError(event, fmt.Errorf("%v, event at tick %d dropped", err, absTick)))
+				continue
+			}
+			if midi2 {
+				var translated []UMP
+				for _, p := range packets {
+					translated = append(translated, translator.MIDI1ToMIDI2(p)...)
+				}
+				packets = translated
+			}
+		}
+		for _, p := range packets {
+			clip.Events = append(clip.Events, &ClipEvent{
+				FilePosition: event.Common().FilePosition,
+				AbsTick:      absTick,
+				Packet:       p,
+			})
+		}
+	}
+	clip.Events = append(clip.Events, &ClipEvent{
+		AbsTick: endTick,
+		Packet:  (&UMPStream{Status: UMPEndOfClip}).UMP(),
+	})
+	err := clip.ConvertAbsToDeltaTick()
+	if err != nil {
+		return nil, err
+	}
+	return clip, nil
+}
+
+// Converts the clip into a format 0 sequence. Header messages are placed at
+// tick 0, MIDI 2.0 messages are translated down to MIDI 1.0, and Flex Data
+// messages with a meta event equivalent become meta events.
+func (clip *Clip) ConvertToSequence(warningCallback WarningCallback) (*Sequence, error) {
+	if clip.TicksPerQuarter == 0 || clip.TicksPerQuarter >= 0x8000 {
+		return nil, newEditError(clip, ErrInvalidDivision)
+	}
+	mtrk := &MTrk{
+		Events: make([]Event, 0),
+	}
+	decoder := NewUMPDecoder(warningCallback)
+	text := make(map[[3]uint8][]byte)
+	endTick := int64(0)
+	for i, events := range [][]*ClipEvent{clip.Header, clip.Events} {
+		for _, ev := range events {
+			absTick := int64(0)
+			if i != 0 {
+				absTick = ev.AbsTick
+			}
+			if absTick > endTick {
+				endTick = absTick
+			}
+			var converted []Event
+			switch ev.Packet.MessageType() {
+			case UMPTypeUtility, UMPTypeStream:
+				continue
+			case UMPTypeFlexData:
+				msg, err := DecodeUMPMessage(ev.Packet)
+				if err != nil {
+					warningCallback(newEditError(ev, err))
+					continue
+				}
+				event, ok := flexDataToMetaEvent(msg.(*UMPFlexData), text)
+				if !ok {
+					warningCallback(newEditError(ev, fmt.Errorf("Flex Data message %s at tick %d has no meta event equivalent, dropped", ev.Packet, absTick)))
+				}
+				if event == nil {
+					continue
+				}
+				converted = []Event{event}
+			default:
+				var err error
+				converted, err = decoder.Decode(ev.Packet)
+				if err != nil {
+					warningCallback(newEditError(ev, err))
+					continue
+				}
+			}
+			for _, event := range converted {
+				evCommon := event.Common()
+				evCommon.FilePosition = ev.FilePosition
+				evCommon.AbsTick = absTick
+				mtrk.Events = append(mtrk.Events, event)
+			}
+		}
+	}
+	mtrk.Events = append(mtrk.Events, &MetaEventEndOfTrack{
+		EventCommon: EventCommon{
+			AbsTick: endTick,
+		},
+	})
+	err := mtrk.ConvertAbsToDeltaTick()
+	if err != nil {
+		return nil, err
+	}
+	seq := &Sequence{
+		Header: &MThd{
+			Format:   0,
+			NTrks:    1,
+			Division: clip.TicksPerQuarter,
+		},
+		Tracks: []*MTrk{mtrk},
+	}
+	seq.CalculateNotePair()
+	seq.CalculateTempoTable()
+	seq.CalculateMeterTable()
+	return seq, nil
+}
+
+// Status bank and status of Flex Data messages
+const (
+	umpFlexDataSetupBank       uint8 = 0x00
+	umpFlexDataMetadataBank    uint8 = 0x01
+	umpFlexDataPerformanceBank uint8 = 0x02
+
+	umpFlexDataSetTempo         uint8 = 0x00
+	umpFlexDataTimeSignature    uint8 = 0x01
+	umpFlexDataKeySignature     uint8 = 0x05
+	umpFlexDataUnknownText      uint8 = 0x00
+	umpFlexDataClipName         uint8 = 0x03
+	umpFlexDataCopyrightNotice  uint8 = 0x04
+	umpFlexDataLyrics           uint8 = 0x01
+	umpFlexDataAddressGroup     uint8 = 0x1
+	umpFlexDataFormComplete     uint8 = 0x0
+	umpFlexDataFormStart        uint8 = 0x1
+	umpFlexDataFormContinue     uint8 = 0x2
+	umpFlexDataFormEnd          uint8 = 0x3
+	umpFlexDataTextBytesPerPart       = 12
+)
+
+// Key signatures in the order of the circle of fifths, starting from F major
+// or D minor
+const fifthsToTonic = "FCGDAEB"
+
+func metaEventToFlexData(ev MetaEvent) []UMP {
+	flex := &UMPFlexData{
+		Address: umpFlexDataAddressGroup,
+	}
+	switch ev := ev.(type) {
+	case *MetaEventSetTempo:
+		flex.Data[0] = ev.UsPerQuarter * 100
+		if ev.UsPerQuarter > 0xffffffff/100 {
+			flex.Data[0] = 0xffffffff
+		}
+		return []UMP{flex.UMP()}
+	case *MetaEventTimeSignature:
+		flex.Status = umpFlexDataTimeSignature
+		flex.Data[0] = uint32(ev.Numerator)<<24 | uint32(ev.Denominator)<<16 | uint32(ev.ThirtySecondNotesPer24MIDIClocks)<<8
+		return []UMP{flex.UMP()}
+	case *MetaEventKeySignature:
+		sf, mi := int8(uint16(ev.KeySignature)>>8), uint8(ev.KeySignature)
+		if sf < -7 || sf > 7 || mi > 1 {
+			return nil
+		}
+		tonic := fifthsToTonic[(int(sf)+1+3*int(mi)+7)%7] - 'A' + 1
+		flex.Status = umpFlexDataKeySignature
+		flex.Data[0] = uint32(uint8(sf)&0xf)<<28 | uint32(tonic)<<24
+		return []UMP{flex.UMP()}
+	case *MetaEventTextEvent:
+		return textToFlexData(flex, umpFlexDataMetadataBank, umpFlexDataUnknownText, ev.Text)
+	case *MetaEventSequenceTrackName:
+		return textToFlexData(flex, umpFlexDataMetadataBank, umpFlexDataClipName, ev.Text)
+	case *MetaEventCopyrightNotice:
+		return textToFlexData(flex, umpFlexDataMetadataBank, umpFlexDataCopyrightNotice, ev.Text)
+	case *MetaEventLyric:
+		return textToFlexData(flex, umpFlexDataPerformanceBank, umpFlexDataLyrics, ev.Text)
+	}
+	return nil
+}
+
+// Splits the text into packets of 12 bytes, padded with zeros
+func textToFlexData(flex *UMPFlexData, bank, status uint8, text string) []UMP {
+	flex.StatusBank, flex.Status = bank, status
+	data := []byte(text)
+	var packets []UMP
+	for first := true; first || len(data) != 0; first = false {
+		var part [umpFlexDataTextBytesPerPart]byte
+		n := copy(part[:], data)
+		data = data[n:]
+		last := len(data) == 0
+		switch {
+		case first && last:
+			flex.Form = umpFlexDataFormComplete
+		case first:
+			flex.Form = umpFlexDataFormStart
+		case last:
+			flex.Form = umpFlexDataFormEnd
+		default:
+			flex.Form = umpFlexDataFormContinue
+		}
+		words := umpFromBytes(part[:])
+		copy(flex.Data[:], words)
+		packets = append(packets, flex.UMP())
+	}
+	return packets
+}
+
+// Returns ok = false for unsupported messages. Text messages that continue in
+// later packets return no event, their parts are kept in text.
+func flexDataToMetaEvent(flex *UMPFlexData, text map[[3]uint8][]byte) (event MetaEvent, ok bool) {
+	switch flex.StatusBank {
+	case umpFlexDataSetupBank:
+		switch flex.Status {
+		case umpFlexDataSetTempo:
+			return &MetaEventSetTempo{
+				UsPerQuarter: uint32(divRound(int64(flex.Data[0]), 100)),
+			}, true
+		case umpFlexDataTimeSignature:
+			return &MetaEventTimeSignature{
+				Numerator:                        uint8(flex.Data[0] >> 24),
+				Denominator:                      uint8(flex.Data[0] >> 16),
+				MIDIClocksPerMetronome:           24,
+				ThirtySecondNotesPer24MIDIClocks: uint8(flex.Data[0] >> 8),
+			}, true
+		case umpFlexDataKeySignature:
+			sf := int8(uint8(flex.Data[0]>>24)) >> 4
+			tonic := uint8(flex.Data[0]>>24) & 0xf
+			if sf < -7 {
+				return nil, false
+			}
+			mi := uint8(0)
+			if tonic >= 1 && tonic <= 7 && fifthsToTonic[(int(sf)+4+7)%7] == 'A'+tonic-1 {
+				mi = 1
+			}
+			return &MetaEventKeySignature{
+				KeySignature: KeySignature(int16(sf)<<8 | int16(mi)),
+			}, true
+		}
+		return nil, false
+	case umpFlexDataMetadataBank, umpFlexDataPerformanceBank:
+	default:
+		return nil, false
+	}
+	key := [3]uint8{flex.Group, flex.StatusBank, flex.Status}
+	var part [umpFlexDataTextBytesPerPart]byte
+	copy(part[:], UMP(flex.Data[:]).EncodeBytes())
+	switch flex.Form {
+	case umpFlexDataFormComplete, umpFlexDataFormStart:
+		text[key] = append([]byte(nil), part[:]...)
+	default:
+		text[key] = append(text[key], part[:]...)
+	}
+	if flex.Form == umpFlexDataFormStart || flex.Form == umpFlexDataFormContinue {
+		return nil, true
+	}
+	str := string(bytes.TrimRight(text[key], "\x00"))
+	delete(text, key)
+	switch {
+	case flex.StatusBank == umpFlexDataMetadataBank && flex.Status == umpFlexDataClipName:
+		return &MetaEventSequenceTrackName{Text: str}, true
+	case flex.StatusBank == umpFlexDataMetadataBank && flex.Status == umpFlexDataCopyrightNotice:
+		return &MetaEventCopyrightNotice{Text: str}, true
+	case flex.StatusBank == umpFlexDataPerformanceBank && flex.Status == umpFlexDataLyrics:
+		return &MetaEventLyric{Text: str}, true
+	case flex.StatusBank == umpFlexDataPerformanceBank && flex.Status != umpFlexDataUnknownText:
+		return nil, false
+	}
+	return &MetaEventTextEvent{Text: str}, true
+}
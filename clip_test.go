@@ -0,0 +1,152 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestClipFlexDataMetaEvents(t *testing.T) {
+	events := []MetaEvent{
+		&MetaEventSetTempo{UsPerQuarter: 500000},
+		&MetaEventSetTempo{UsPerQuarter: 428571},
+		&MetaEventTimeSignature{Numerator: 6, Denominator: 3, MIDIClocksPerMetronome: 24, ThirtySecondNotesPer24MIDIClocks: 8},
+		&MetaEventTextEvent{Text: ""},
+		&MetaEventSequenceTrackName{Text: "Piano"},
+		&MetaEventCopyrightNotice{Text: "(C) 2018 a long copyright notice"},
+		&MetaEventLyric{Text: "twelve bytes"},
+	}
+	for ks := range keySignatureToString {
+		events = append(events, &MetaEventKeySignature{KeySignature: ks})
+	}
+	for _, event := range events {
+		packets := metaEventToFlexData(event)
+		if len(packets) == 0 {
+			t.Errorf("%+v has no Flex Data form", event)
+			continue
+		}
+		text := make(map[[3]uint8][]byte)
+		var decoded MetaEvent
+		for i, p := range packets {
+			msg, err := DecodeUMPMessage(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ok bool
+			decoded, ok = flexDataToMetaEvent(msg.(*UMPFlexData), text)
+			if !ok || (decoded == nil) != (i != len(packets)-1) {
+				t.Errorf("%+v: packet %d of %v decoded to %+v, %v", event, i, packets, decoded, ok)
+			}
+		}
+		if !reflect.DeepEqual(decoded, event) {
+			t.Errorf("%+v converted back to %+v", event, decoded)
+		}
+	}
+}
+
+func TestClipSequenceRoundTrip(t *testing.T) {
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{{Events: []Event{
+			&MetaEventSetTempo{UsPerQuarter: 600000},
+			&MetaEventKeySignature{KeySignature: KeyEbMin},
+			&EventNoteOn{EventCommon: EventCommon{Channel: 1}, Key: 60, Velocity: 100},
+			&EventNoteOff{EventCommon: EventCommon{DeltaTick: 96, Channel: 1}, Key: 60, Velocity: 64},
+			&MetaEventEndOfTrack{EventCommon: EventCommon{DeltaTick: 0x200000}},
+		}}},
+	}
+	var want bytes.Buffer
+	err := seq.EncodeSMF(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, midi2 := range []bool{false, true} {
+		clip, err := seq.ConvertToClip(midi2, func(err error) {
+			t.Errorf("midi2=%v: %v", midi2, err)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var file bytes.Buffer
+		err = clip.EncodeClip(&file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeClip(bytes.NewReader(file.Bytes()), func(err error) {
+			t.Errorf("midi2=%v: %v", midi2, err)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := decoded.ConvertToSequence(func(err error) {
+			t.Errorf("midi2=%v: %v", midi2, err)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		err = converted.EncodeSMF(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("midi2=%v: converted back to\n% x\nwant\n% x", midi2, got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestDecodeClipKeepsTrailingBytes(t *testing.T) {
+	file := []byte("SMF2CLIP")
+	for _, p := range []UMP{
+		{0x00400000}, {0x00300060},
+		{0x00000000},
+		{0x00400000}, {0xf0200000, 0, 0, 0},
+		{0x00400060}, {0x20903c64},
+		{0x00400000}, {0xf0210000, 0, 0, 0},
+	} {
+		file = append(file, p.EncodeBytes()...)
+	}
+	file = append(file, 0x40, 0x90)
+	clip, err := DecodeClip(bytes.NewReader(file), func(err error) {
+		t.Error(err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clip.TicksPerQuarter != 96 || len(clip.Header) != 0 || len(clip.Events) != 2 || clip.Events[0].AbsTick != 96 || !bytes.Equal(clip.Undecoded, []byte{0x40, 0x90}) {
+		t.Errorf("decoded %+v", clip)
+	}
+	var buf bytes.Buffer
+	err = clip.EncodeClip(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the NOOP message is lost
+	if want := append(append([]byte(nil), file[:16]...), file[20:]...); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoded\n% x\nwant\n% x", buf.Bytes(), want)
+	}
+}
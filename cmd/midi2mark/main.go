@@ -36,6 +36,13 @@ func warningCallback(err error) {
 	log.Println(err)
 }
 
+func openInput(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
 func main() {
 	var input, output *os.File
 	var err error
@@ -47,14 +54,14 @@ func main() {
 		fmt.Printf("Usage: %s INPUT.mid [OUTPUT.midml]\n\n", os.Args[0])
 		os.Exit(1)
 	case 2:
-		input, err = os.Open(os.Args[1])
+		input, err = openInput(os.Args[1])
 		if err != nil {
 			log.Fatalln(err)
 		}
 		defer input.Close()
 		output = os.Stdout
 	case 3:
-		input, err = os.Open(os.Args[1])
+		input, err = openInput(os.Args[1])
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -65,7 +72,12 @@ func main() {
 		}
 		defer output.Close()
 	}
-	sequence, err := midimark.DecodeSequenceFromSMF(input, warningCallback)
+	var sequence *midimark.Sequence
+	if input == os.Stdin {
+		sequence, err = midimark.DecodeSequenceFromStream(input, warningCallback)
+	} else {
+		sequence, err = midimark.DecodeSequenceFromSMF(input, warningCallback)
+	}
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -0,0 +1,209 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+type ControllerKind uint8
+
+const (
+	// Control Change 0-31 with its LSB on 32-63
+	Controller14Bit ControllerKind = iota
+	// Registered Parameter Number, selected by Control Change 101 and 100
+	ControllerRPN
+	// Non-Registered Parameter Number, selected by Control Change 99 and 98
+	ControllerNRPN
+)
+
+const (
+	RPNPitchBendSensitivity uint16 = 0x0000
+	RPNChannelFineTuning    uint16 = 0x0001
+	RPNChannelCoarseTuning  uint16 = 0x0002
+	RPNTuningProgramChange  uint16 = 0x0003
+	RPNTuningBankSelect     uint16 = 0x0004
+	RPNModulationDepthRange uint16 = 0x0005
+	RPNNull                 uint16 = 0x3fff
+)
+
+// ControllerChange is a logical controller change, made up of several Control
+// Change events.
+// Number is the controller number for 14-bit controllers, or the 14-bit
+// parameter number otherwise. Value is always 14-bit, with the MSB in the
+// upper 7 bits.
+type ControllerChange struct {
+	EventCommon
+	Kind   ControllerKind
+	Number uint16
+	Value  uint16
+}
+
+// ControllerDecoder assembles Control Change events into logical controller
+// changes.
+// A change is reported when the MSB arrives, and again when the LSB arrives.
+// As the MIDI specification requires, receiving an MSB resets the LSB to 0.
+type ControllerDecoder struct {
+	channels [16]controllerState
+}
+
+type controllerState struct {
+	msb         [32]uint8
+	lsb         [32]uint8
+	rpn         [2]uint8
+	nrpn        [2]uint8
+	parameter   ControllerKind
+	hasSelected bool
+	rpnValues   map[uint16]uint16
+	nrpnValues  map[uint16]uint16
+}
+
+func NewControllerDecoder() *ControllerDecoder {
+	d := &ControllerDecoder{}
+	d.Reset()
+	return d
+}
+
+func (d *ControllerDecoder) Reset() {
+	for i := range d.channels {
+		d.channels[i] = controllerState{
+			rpn:        [2]uint8{0x7f, 0x7f},
+			nrpn:       [2]uint8{0x7f, 0x7f},
+			rpnValues:  make(map[uint16]uint16),
+			nrpnValues: make(map[uint16]uint16),
+		}
+	}
+}
+
+// Returns false if the event is not part of a logical controller change, or if
+// no parameter has been selected for a Data Entry
+func (d *ControllerDecoder) Decode(ev *EventControlChange) (change ControllerChange, ok bool) {
+	if ev.Channel-1 >= 16 {
+		return ControllerChange{}, false
+	}
+	state := &d.channels[ev.Channel-1]
+	change.EventCommon = ev.EventCommon
+	value := uint16(ev.Value & 0x7f)
+	switch ev.Control {
+	case 101, 100:
+		state.rpn[101-ev.Control] = ev.Value & 0x7f
+		state.parameter = ControllerRPN
+		state.hasSelected = true
+		return ControllerChange{}, false
+	case 99, 98:
+		state.nrpn[99-ev.Control] = ev.Value & 0x7f
+		state.parameter = ControllerNRPN
+		state.hasSelected = true
+		return ControllerChange{}, false
+	case 121:
+		// Reset All Controllers deselects the parameter, as recommended by RP-015
+		state.rpn = [2]uint8{0x7f, 0x7f}
+		state.nrpn = [2]uint8{0x7f, 0x7f}
+		state.hasSelected = false
+		return ControllerChange{}, false
+	case 6, 38, 96, 97:
+		if !state.hasSelected {
+			return ControllerChange{}, false
+		}
+		change.Kind = state.parameter
+		number, values := state.rpn, state.rpnValues
+		if state.parameter == ControllerNRPN {
+			number, values = state.nrpn, state.nrpnValues
+		}
+		change.Number = uint16(number[0])<<7 | uint16(number[1])
+		if change.Number == RPNNull {
+			return ControllerChange{}, false
+		}
+		parameterValue := values[change.Number]
+		switch ev.Control {
+		case 6:
+			parameterValue = value << 7
+		case 38:
+			parameterValue = parameterValue&0x3f80 | value
+		case 96:
+			if parameterValue < 0x3fff {
+				parameterValue++
+			}
+		case 97:
+			if parameterValue > 0 {
+				parameterValue--
+			}
+		}
+		values[change.Number] = parameterValue
+		change.Value = parameterValue
+		return change, true
+	}
+	switch {
+	case ev.Control < 32:
+		state.msb[ev.Control] = uint8(value)
+		state.lsb[ev.Control] = 0
+		change.Number = uint16(ev.Control)
+	case ev.Control < 64:
+		state.lsb[ev.Control-32] = uint8(value)
+		change.Number = uint16(ev.Control - 32)
+	default:
+		return ControllerChange{}, false
+	}
+	change.Kind = Controller14Bit
+	change.Value = uint16(state.msb[change.Number])<<7 | uint16(state.lsb[change.Number])
+	return change, true
+}
+
+// Expands the change into Control Change events in transmission order: the
+// parameter number first if any, then the MSB, then the LSB.
+// The first event takes the timing of the change, the others follow it
+// immediately.
+func (change *ControllerChange) ControlChanges() []*EventControlChange {
+	newEvent := func(control, value uint8) *EventControlChange {
+		return &EventControlChange{
+			EventCommon: EventCommon{
+				AbsTick: change.AbsTick,
+				Channel: change.Channel,
+			},
+			Control: control,
+			Value:   value & 0x7f,
+		}
+	}
+	msb, lsb := uint8(change.Value>>7), uint8(change.Value)
+	var events []*EventControlChange
+	switch change.Kind {
+	case Controller14Bit:
+		events = []*EventControlChange{
+			newEvent(uint8(change.Number)&0x1f, msb),
+			newEvent(uint8(change.Number)&0x1f+32, lsb),
+		}
+	case ControllerRPN, ControllerNRPN:
+		selectMSB, selectLSB := uint8(101), uint8(100)
+		if change.Kind == ControllerNRPN {
+			selectMSB, selectLSB = 99, 98
+		}
+		events = []*EventControlChange{
+			newEvent(selectMSB, uint8(change.Number>>7)),
+			newEvent(selectLSB, uint8(change.Number)),
+			newEvent(6, msb),
+			newEvent(38, lsb),
+		}
+	}
+	if len(events) != 0 {
+		events[0].DeltaTick = change.DeltaTick
+	}
+	return events
+}
@@ -0,0 +1,108 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestControllerChangeRoundTrip(t *testing.T) {
+	tests := []struct {
+		change ControllerChange
+		data   []byte
+	}{
+		{
+			// Pitch bend range of 12 semitones and 50 cents
+			ControllerChange{EventCommon: EventCommon{Channel: 1}, Kind: ControllerRPN, Number: RPNPitchBendSensitivity, Value: 12<<7 | 50},
+			[]byte{0xb0, 0x65, 0x00, 0xb0, 0x64, 0x00, 0xb0, 0x06, 0x0c, 0xb0, 0x26, 0x32},
+		},
+		{
+			ControllerChange{EventCommon: EventCommon{Channel: 16}, Kind: ControllerNRPN, Number: 0x0123, Value: 0x1fff},
+			[]byte{0xbf, 0x63, 0x02, 0xbf, 0x62, 0x23, 0xbf, 0x06, 0x3f, 0xbf, 0x26, 0x7f},
+		},
+		{
+			ControllerChange{EventCommon: EventCommon{Channel: 3}, Kind: Controller14Bit, Number: 7, Value: 0x2abc},
+			[]byte{0xb2, 0x07, 0x55, 0xb2, 0x27, 0x3c},
+		},
+	}
+	for _, test := range tests {
+		events := test.change.ControlChanges()
+		var data []byte
+		for _, ev := range events {
+			b, err := ev.EncodeRealtime()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = append(data, b...)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Errorf("%+v: encoded % x, want % x", test.change, data, test.data)
+		}
+		d := NewControllerDecoder()
+		var decoded ControllerChange
+		for _, ev := range events {
+			if change, ok := d.Decode(ev); ok {
+				decoded = change
+			}
+		}
+		if decoded.Kind != test.change.Kind || decoded.Number != test.change.Number || decoded.Value != test.change.Value || decoded.Channel != test.change.Channel {
+			t.Errorf("%+v: decoded to %+v", test.change, decoded)
+		}
+	}
+}
+
+func TestControllerDecoder(t *testing.T) {
+	d := NewControllerDecoder()
+	cc := func(control, value uint8) (ControllerChange, bool) {
+		return d.Decode(&EventControlChange{EventCommon: EventCommon{Channel: 1}, Control: control, Value: value})
+	}
+	// A new MSB resets the LSB
+	cc(7, 0x55)
+	cc(39, 0x3c)
+	if change, ok := cc(7, 0x20); !ok || change.Value != 0x1000 {
+		t.Errorf("got %+v, want value 0x1000", change)
+	}
+	// Data Entry without a selected parameter is ignored
+	if change, ok := cc(6, 0x0c); ok {
+		t.Errorf("got %+v without a selected parameter", change)
+	}
+	cc(101, 0)
+	cc(100, 0)
+	cc(6, 0x02)
+	if change, ok := cc(96, 0); !ok || change.Kind != ControllerRPN || change.Value != 0x0101 {
+		t.Errorf("Data Increment: got %+v, want RPN 0 value 0x101", change)
+	}
+	// Reset All Controllers deselects the parameter, so does RPN Null
+	cc(121, 0)
+	if change, ok := cc(6, 0x0c); ok {
+		t.Errorf("got %+v after Reset All Controllers", change)
+	}
+	cc(101, 0x7f)
+	cc(100, 0x7f)
+	if change, ok := cc(6, 0x0c); ok {
+		t.Errorf("got %+v after RPN Null", change)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrInvalidDivision = errors.New("midimark: invalid division")
+
+// Rescales every event in the sequence to a new number of ticks per quarter
+// note (or ticks per frame for SMPTE timing).
+// Ticks are rounded to the nearest value, which keeps events in order. A note
+// that would otherwise become zero-length is extended by one tick, and a
+// warning is reported.
+func (seq *Sequence) ChangeDivision(division uint16, warningCallback WarningCallback) error {
+	if seq.Header.Division == 0 || division == 0 || (seq.Header.Framerate == 0 && division >= 0x8000) || (seq.Header.Framerate != 0 && division >= 0x100) {
+		return ErrInvalidDivision
+	}
+	oldDivision := int64(seq.Header.Division)
+	newDivision := int64(division)
+
+	seq.ConvertDeltaToAbsTick()
+	seq.CalculateNotePair()
+	// Keep the original order and timing to restore if the new deltas do not fit
+	restore := seq.saveEventTicks()
+	var notes []*EventNoteOn
+	for _, mtrk := range seq.Tracks {
+		for _, event := range mtrk.Events {
+			if ev, ok := event.(*EventNoteOn); ok && ev.RelatedNoteOff != nil && ev.RelatedNoteOff.AbsTick > ev.AbsTick {
+				notes = append(notes, ev)
+			}
+		}
+	}
+	for _, mtrk := range seq.Tracks {
+		for _, event := range mtrk.Events {
+			evCommon := event.Common()
+			evCommon.AbsTick = divRound(evCommon.AbsTick*newDivision, oldDivision)
+		}
+	}
+	for _, noteOn := range notes {
+		noteOff := noteOn.RelatedNoteOff
+		if noteOff.AbsTick <= noteOn.AbsTick {
+			noteOff.AbsTick = noteOn.AbsTick + 1
+			warningCallback(newEditError(noteOn, fmt.Errorf("note %s at tick %d extended to avoid zero length", noteOn.Key, noteOn.AbsTick)))
+		}
+	}
+
+	for _, mtrk := range seq.Tracks {
+		sortEvents(mtrk)
+	}
+	err := seq.ConvertAbsToDeltaTick()
+	if err != nil {
+		restore()
+		return err
+	}
+	seq.Header.Division = division
+	seq.CalculateNotePair()
+	seq.CalculateTempoTable()
+	seq.CalculateMeterTable()
+	return nil
+}
+
+// Stably sorts events by AbsTick, keeping End of Track events at the end of the track
+// Returns a function that puts every event back in its current order and at
+// its current tick
+func (seq *Sequence) saveEventTicks() func() {
+	oldEvents := make([][]Event, len(seq.Tracks))
+	oldAbsTicks := make([][]int64, len(seq.Tracks))
+	for i, mtrk := range seq.Tracks {
+		oldEvents[i] = append([]Event(nil), mtrk.Events...)
+		oldAbsTicks[i] = make([]int64, len(mtrk.Events))
+		for j, event := range mtrk.Events {
+			oldAbsTicks[i][j] = event.Common().AbsTick
+		}
+	}
+	return func() {
+		for i, mtrk := range seq.Tracks {
+			mtrk.Events = oldEvents[i]
+			for j, event := range mtrk.Events {
+				event.Common().AbsTick = oldAbsTicks[i][j]
+			}
+		}
+		seq.ConvertAbsToDeltaTick()
+	}
+}
+
+func sortEvents(mtrk *MTrk) {
+	endTick := int64(0)
+	for _, event := range mtrk.Events {
+		if absTick := event.Common().AbsTick; absTick > endTick {
+			endTick = absTick
+		}
+	}
+	for _, event := range mtrk.Events {
+		if ev, ok := event.(*MetaEventEndOfTrack); ok {
+			ev.AbsTick = endTick
+		}
+	}
+	sort.SliceStable(mtrk.Events, func(i, j int) bool {
+		a, b := mtrk.Events[i].Common().AbsTick, mtrk.Events[j].Common().AbsTick
+		if a != b {
+			return a < b
+		}
+		_, aEnd := mtrk.Events[i].(*MetaEventEndOfTrack)
+		_, bEnd := mtrk.Events[j].(*MetaEventEndOfTrack)
+		return !aEnd && bEnd
+	})
+}
@@ -0,0 +1,59 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import "testing"
+
+func TestChangeDivisionRestoresOnError(t *testing.T) {
+	events := []Event{
+		&EventNoteOn{EventCommon: EventCommon{Channel: 1}, Key: 60, Velocity: 100},
+		&EventNoteOff{EventCommon: EventCommon{DeltaTick: MaxVLQ, Channel: 1}, Key: 60, Velocity: 64},
+		&MetaEventEndOfTrack{},
+	}
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{{Events: append([]Event(nil), events...)}},
+	}
+	err := seq.ChangeDivision(960, IgnoreWarnings)
+	if err != ErrDeltaToBig {
+		t.Fatalf("got error %v, want %v", err, ErrDeltaToBig)
+	}
+	if seq.Header.Division != 96 {
+		t.Errorf("division changed to %d on error", seq.Header.Division)
+	}
+	for i, event := range seq.Tracks[0].Events {
+		evCommon := event.Common()
+		if event != events[i] {
+			t.Errorf("event %d reordered", i)
+		}
+		wantTick := int64(0)
+		if i != 0 {
+			wantTick = MaxVLQ
+		}
+		if evCommon.AbsTick != wantTick || (i == 1) != (evCommon.DeltaTick == MaxVLQ) {
+			t.Errorf("event %d: tick %d delta %d not restored", i, evCommon.AbsTick, evCommon.DeltaTick)
+		}
+	}
+}
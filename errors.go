@@ -49,6 +49,11 @@ type ErrXMLDecode struct {
 	Err error
 }
 
+type ErrEdit struct {
+	Obj interface{}
+	Err error
+}
+
 func newSMFEncodeError(obj interface{}, err error) *ErrSMFEncode {
 	return &ErrSMFEncode{
 		Obj: obj,
@@ -70,6 +75,13 @@ func newXMLDecodeError(Obj etree.Token, err error) *ErrXMLDecode {
 	}
 }
 
+func newEditError(obj interface{}, err error) *ErrEdit {
+	return &ErrEdit{
+		Obj: obj,
+		Err: err,
+	}
+}
+
 func (e *ErrSMFEncode) Error() string {
 	return fmt.Sprintf("MIDI encode error: %v", e.Err)
 }
@@ -84,3 +96,7 @@ func (e *ErrSMFDecode) Error() string {
 func (e *ErrXMLDecode) Error() string {
 	return fmt.Sprintf("MIDI Markup decode error: %v", e.Err)
 }
+
+func (e *ErrEdit) Error() string {
+	return fmt.Sprintf("MIDI edit error: %v", e.Err)
+}
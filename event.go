@@ -482,11 +482,11 @@ func (ev *EventPitchWheelChange) EncodeSMF(w io.Writer, status, channel *uint8)
 		return err
 	}
 	if *status == ev.Status() {
-		_, err = w.Write([]byte{uint8((ev.Pitch+0x2000)>>7) & 0x7f, uint8(ev.Pitch+0x2000) & 0x7f})
+		_, err = w.Write([]byte{uint8(ev.Pitch+0x2000) & 0x7f, uint8((ev.Pitch+0x2000)>>7) & 0x7f})
 	} else {
 		*status = ev.Status()
 		*channel = ev.Channel
-		_, err = w.Write([]byte{ev.Status(), uint8((ev.Pitch+0x2000)>>7) & 0x7f, uint8(ev.Pitch+0x2000) & 0x7f})
+		_, err = w.Write([]byte{ev.Status(), uint8(ev.Pitch+0x2000) & 0x7f, uint8((ev.Pitch+0x2000)>>7) & 0x7f})
 	}
 	return err
 }
@@ -519,7 +519,7 @@ func (ev *EventPitchWheelChange) EncodeRealtime() ([]byte, error) {
 	if ev.Pitch >= 0x2000 || ev.Pitch < -0x2000 {
 		return nil, newSMFEncodeError(ev, fmt.Errorf("invalid pitch value %d", ev.Pitch))
 	}
-	return []byte{ev.Status(), uint8((ev.Pitch+0x2000)>>7) & 0x7f, uint8(ev.Pitch+0x2000) & 0x7f}, nil
+	return []byte{ev.Status(), uint8(ev.Pitch+0x2000) & 0x7f, uint8((ev.Pitch+0x2000)>>7) & 0x7f}, nil
 }
 
 func (ev *EventPitchWheelChange) EncodeXML() *etree.Element {
@@ -630,6 +630,12 @@ func (ev *EventSystemExclusive) EncodeRealtime() ([]byte, error) {
 }
 
 func (ev *EventSystemExclusive) EncodeXML() *etree.Element {
+	if sysex := decodeExactSysEx(ev.Data); sysex != nil {
+		el := etree.NewElement(sysex.sysexTag())
+		ev.encodeCommonXMLAttr(el)
+		sysex.encodeXMLAttr(el)
+		return el
+	}
 	el := etree.NewElement("SysEx")
 	ev.encodeCommonXMLAttr(el)
 	el.CreateAttr("data", fmt.Sprintf("% x", ev.Data))
@@ -721,7 +727,7 @@ func (ev *EventSongPositionPointer) EncodeSMF(w io.Writer, status, channel *uint
 			return err
 		}
 	}
-	_, err = w.Write([]byte{ev.Status(), uint8(ev.SongPosition>>7) & 0x7f, uint8(ev.SongPosition) & 0x7f})
+	_, err = w.Write([]byte{ev.Status(), uint8(ev.SongPosition) & 0x7f, uint8(ev.SongPosition>>7) & 0x7f})
 	return err
 }
 
@@ -753,7 +759,7 @@ func (ev *EventSongPositionPointer) EncodeRealtime() ([]byte, error) {
 	if ev.SongPosition >= 0x4000 {
 		return nil, newSMFEncodeError(ev, fmt.Errorf("invalid song position %d", ev.SongPosition))
 	}
-	return []byte{ev.Status(), uint8(ev.SongPosition>>7) & 0x7f, uint8(ev.SongPosition) & 0x7f}, nil
+	return []byte{ev.Status(), uint8(ev.SongPosition) & 0x7f, uint8(ev.SongPosition>>7) & 0x7f}, nil
 }
 
 func (ev *EventSongPositionPointer) Status() uint8 {
@@ -952,6 +958,15 @@ func (ev *EventEscape) EncodeRealtime() ([]byte, error) {
 }
 
 func (ev *EventEscape) EncodeXML() *etree.Element {
+	if len(ev.Data) != 0 && ev.Data[0] == 0xf0 {
+		if sysex := decodeExactSysEx(ev.Data[1:]); sysex != nil {
+			el := etree.NewElement(sysex.sysexTag())
+			ev.encodeCommonXMLAttr(el)
+			sysex.encodeXMLAttr(el)
+			el.CreateAttr("escape", "yes")
+			return el
+		}
+	}
 	el := etree.NewElement("Escape")
 	ev.encodeCommonXMLAttr(el)
 	el.CreateAttr("data", fmt.Sprintf("% x", ev.Data))
@@ -1429,7 +1444,7 @@ func decodeEvent(r io.ReadSeeker, realtime bool, status, channel *uint8, warning
 		}
 		event = &EventPitchWheelChange{
 			EventCommon: eventCommon,
-			Pitch:       (int16(buf[1]&0x7f)<<7 | int16(buf[2]&0x7f)) - 0x2000,
+			Pitch:       (int16(buf[2]&0x7f)<<7 | int16(buf[1]&0x7f)) - 0x2000,
 		}
 	default:
 		switch buf[0] {
@@ -1478,7 +1493,7 @@ func decodeEvent(r io.ReadSeeker, realtime bool, status, channel *uint8, warning
 			}
 			event = &EventSongPositionPointer{
 				EventCommon:  eventCommon,
-				SongPosition: uint16(buf[1]&0x7f)<<7 | uint16(buf[2]&0x7f),
+				SongPosition: uint16(buf[2]&0x7f)<<7 | uint16(buf[1]&0x7f),
 			}
 		case 0xf3:
 			_, err = io.ReadFull(r, buf[1:2])
@@ -1787,6 +1802,36 @@ func DecodeEventFromXML(el *etree.Element) (Event, error) {
 			Unknown:     unknown,
 		}, nil
 	default:
+		sysex, err := decodeSysExFromXML(el)
+		if err != nil {
+			return nil, err
+		}
+		if sysex != nil {
+			switch el.SelectAttrValue("escape", "no") {
+			case "yes":
+				return &EventEscape{
+					EventCommon: eventCommon,
+					Data:        append([]byte{0xf0}, sysex.SysExData()...),
+				}, nil
+			case "no":
+			default:
+				return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: escape=%q", el.Tag, el.SelectAttrValue("escape", "")))
+			}
+			return &EventSystemExclusive{
+				EventCommon: eventCommon,
+				Data:        sysex.SysExData(),
+			}, nil
+		}
+		xf, err := decodeXFMetaFromXML(el)
+		if err != nil {
+			return nil, err
+		}
+		if xf != nil {
+			return &MetaEventSequencerSpecific{
+				EventCommon: eventCommon,
+				Data:        xf.XFData(),
+			}, nil
+		}
 		return nil, newXMLDecodeError(el, fmt.Errorf("expect an event, but got <%s>", el.Tag))
 	}
 }
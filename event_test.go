@@ -0,0 +1,99 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSongPositionPointerByteOrder(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		position uint16
+	}{
+		{[]byte{0xf2, 0x00, 0x00}, 0},
+		{[]byte{0xf2, 0x10, 0x00}, 16},
+		{[]byte{0xf2, 0x00, 0x01}, 128},
+		{[]byte{0xf2, 0x7f, 0x7f}, 0x3fff},
+	}
+	for _, test := range tests {
+		status := uint8(0)
+		event, err := DecodeEventFromRealtime(bytes.NewReader(test.data), &status, IgnoreWarnings)
+		if err != nil {
+			t.Fatalf("% x: %v", test.data, err)
+		}
+		ev, ok := event.(*EventSongPositionPointer)
+		if !ok || ev.SongPosition != test.position {
+			t.Errorf("% x: decoded %+v, want song position %d", test.data, event, test.position)
+			continue
+		}
+		data, err := ev.EncodeRealtime()
+		if err != nil || !bytes.Equal(data, test.data) {
+			t.Errorf("song position %d: encoded % x, want % x", test.position, data, test.data)
+		}
+		var buf bytes.Buffer
+		status, channel := uint8(0), uint8(0)
+		err = ev.EncodeSMF(&buf, &status, &channel)
+		if err != nil || !bytes.Equal(buf.Bytes(), append([]byte{0x00}, test.data...)) {
+			t.Errorf("song position %d: encoded SMF % x, want 00 % x", test.position, buf.Bytes(), test.data)
+		}
+	}
+}
+
+func TestPitchWheelChangeByteOrder(t *testing.T) {
+	tests := []struct {
+		data  []byte
+		pitch int16
+	}{
+		{[]byte{0xe0, 0x00, 0x40}, 0},
+		{[]byte{0xe0, 0x00, 0x00}, -0x2000},
+		{[]byte{0xe0, 0x01, 0x40}, 1},
+		{[]byte{0xe0, 0x7f, 0x3f}, -1},
+		{[]byte{0xe0, 0x7f, 0x7f}, 0x1fff},
+	}
+	for _, test := range tests {
+		status := uint8(0)
+		event, err := DecodeEventFromRealtime(bytes.NewReader(test.data), &status, IgnoreWarnings)
+		if err != nil {
+			t.Fatalf("% x: %v", test.data, err)
+		}
+		ev, ok := event.(*EventPitchWheelChange)
+		if !ok || ev.Pitch != test.pitch {
+			t.Errorf("% x: decoded %+v, want pitch %d", test.data, event, test.pitch)
+			continue
+		}
+		data, err := ev.EncodeRealtime()
+		if err != nil || !bytes.Equal(data, test.data) {
+			t.Errorf("pitch %d: encoded % x, want % x", test.pitch, data, test.data)
+		}
+		var buf bytes.Buffer
+		status, channel := uint8(0), uint8(0)
+		err = ev.EncodeSMF(&buf, &status, &channel)
+		if err != nil || !bytes.Equal(buf.Bytes(), append([]byte{0x00}, test.data...)) {
+			t.Errorf("pitch %d: encoded SMF % x, want 00 % x", test.pitch, buf.Bytes(), test.data)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+)
+
+var ErrFormat2Conversion = errors.New("midimark: can not convert a format 2 sequence, its tracks are independent patterns")
+
+// Merges all tracks into a single track, keeping one End of Track event at the end.
+func (seq *Sequence) ConvertToFormat0() error {
+	if seq.Header.Format == 2 {
+		return ErrFormat2Conversion
+	}
+	mtrk := &MTrk{
+		Events: make([]Event, 0),
+	}
+	if len(seq.Tracks) != 0 {
+		mtrk.FilePosition = seq.Tracks[0].FilePosition
+	}
+	var endOfTrack *MetaEventEndOfTrack
+	endTick := int64(0)
+	prefix := make([]uint8, len(seq.Tracks))
+	channel := uint8(0)
+	it := newEventIterator(seq)
+	for {
+		track, event, absTick, ok := it.next()
+		if !ok {
+			break
+		}
+		evCommon := event.Common()
+		evCommon.AbsTick = absTick
+		if absTick > endTick {
+			endTick = absTick
+		}
+		switch ev := event.(type) {
+		case *MetaEventEndOfTrack:
+			endOfTrack = ev
+			continue
+		case *MetaEventMIDIChannelPrefix:
+			prefix[track] = ev.ChannelPrefix
+			channel = ev.ChannelPrefix
+		default:
+			if isChannelEvent(event) {
+				prefix[track] = 0
+				channel = evCommon.Channel
+			} else if prefix[track] == 0 {
+				// Only keep channel prefixes that were explicitly written in the file
+				evCommon.Channel = channel
+			}
+		}
+		mtrk.Events = append(mtrk.Events, event)
+	}
+	if endOfTrack == nil {
+		endOfTrack = &MetaEventEndOfTrack{}
+	}
+	endOfTrack.AbsTick = endTick
+	endOfTrack.Channel = channel
+	mtrk.Events = append(mtrk.Events, endOfTrack)
+	err := mtrk.ConvertAbsToDeltaTick()
+	if err != nil {
+		return err
+	}
+
+	seq.Header.Format = 0
+	seq.Header.NTrks = 1
+	seq.Tracks = []*MTrk{mtrk}
+	seq.CalculateNotePair()
+	seq.CalculateTempoTable()
+	seq.CalculateMeterTable()
+	return nil
+}
+
+// Splits the sequence into a conductor track holding tempo, meta and system
+// events, followed by one track per MIDI channel in use.
+func (seq *Sequence) ConvertToFormat1() error {
+	if seq.Header.Format == 2 {
+		return ErrFormat2Conversion
+	}
+	conductor := &MTrk{
+		Events: make([]Event, 0),
+	}
+	if len(seq.Tracks) != 0 {
+		conductor.FilePosition = seq.Tracks[0].FilePosition
+	}
+	var channelTracks [16]*MTrk
+	var endOfTrack *MetaEventEndOfTrack
+	endTick := int64(0)
+	prefix := make([]uint8, len(seq.Tracks))
+	it := newEventIterator(seq)
+	for {
+		track, event, absTick, ok := it.next()
+		if !ok {
+			break
+		}
+		evCommon := event.Common()
+		evCommon.AbsTick = absTick
+		if absTick > endTick {
+			endTick = absTick
+		}
+		channel := uint8(0)
+		switch ev := event.(type) {
+		case *MetaEventEndOfTrack:
+			if endOfTrack == nil {
+				endOfTrack = ev
+			}
+			continue
+		case *MetaEventMIDIChannelPrefix:
+			// The encoder writes channel prefixes back when needed
+			prefix[track] = ev.ChannelPrefix
+			continue
+		default:
+			if isChannelEvent(event) {
+				prefix[track] = 0
+				channel = evCommon.Channel
+			} else {
+				channel = prefix[track]
+				evCommon.Channel = channel
+			}
+		}
+		dest := conductor
+		if channel-1 < 16 {
+			if channelTracks[channel-1] == nil {
+				channelTracks[channel-1] = &MTrk{
+					Events: make([]Event, 0),
+				}
+			}
+			dest = channelTracks[channel-1]
+		}
+		dest.Events = append(dest.Events, event)
+	}
+
+	tracks := []*MTrk{conductor}
+	for _, mtrk := range channelTracks {
+		if mtrk != nil {
+			tracks = append(tracks, mtrk)
+		}
+	}
+	for i, mtrk := range tracks {
+		eot := endOfTrack
+		if i != 0 || eot == nil {
+			eot = &MetaEventEndOfTrack{}
+		}
+		eot.AbsTick = endTick
+		eot.Channel = 0
+		if len(mtrk.Events) != 0 {
+			eot.Channel = mtrk.Events[len(mtrk.Events)-1].Common().Channel
+		}
+		mtrk.Events = append(mtrk.Events, eot)
+		err := mtrk.ConvertAbsToDeltaTick()
+		if err != nil {
+			return err
+		}
+	}
+
+	seq.Header.Format = 1
+	if len(tracks) < 0xffff {
+		seq.Header.NTrks = uint16(len(tracks))
+	} else {
+		seq.Header.NTrks = 0xffff
+	}
+	seq.Tracks = tracks
+	seq.CalculateNotePair()
+	seq.CalculateTempoTable()
+	seq.CalculateMeterTable()
+	return nil
+}
+
+func isChannelEvent(event Event) bool {
+	switch ev := event.(type) {
+	case *EventNoteOff, *EventNoteOn, *EventPolyphonicKeyPressure, *EventControlChange, *EventProgramChange, *EventChannelPressure, *EventPitchWheelChange:
+		return true
+	case *EventUnknown:
+		return len(ev.Unknown) != 0 && ev.Unknown[0] >= 0x80 && ev.Unknown[0] < 0xf0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,150 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func decodeFormatTestSequence(t *testing.T) *Sequence {
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 3, 0, 96}
+	data = append(data, buildTestMTrk(
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20,
+		0x00, 0xff, 0x58, 0x04, 0x04, 0x02, 0x18, 0x08,
+		0x00, 0xff, 0x2f, 0x00,
+	)...)
+	// A lyric for channel 2 in the middle of a channel 1 track
+	data = append(data, buildTestMTrk(
+		0x00, 0x90, 0x3c, 0x64,
+		0x30, 0xff, 0x20, 0x01, 0x01,
+		0x00, 0xff, 0x05, 0x02, 'l', 'a',
+		0x30, 0x80, 0x3c, 0x40,
+		0x00, 0xff, 0x2f, 0x00,
+	)...)
+	data = append(data, buildTestMTrk(
+		0x00, 0x91, 0x40, 0x64,
+		0x0a, 0xc2, 0x05,
+		0x81, 0x36, 0x81, 0x40, 0x40,
+		0x00, 0xff, 0x2f, 0x00,
+	)...)
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq
+}
+
+// Checks every track ends with its only End of Track event, at the end of the sequence
+func checkFormatTestTracks(t *testing.T, name string, seq *Sequence) {
+	for i, mtrk := range seq.Tracks {
+		for j, event := range mtrk.Events {
+			if _, ok := event.(*MetaEventEndOfTrack); ok != (j == len(mtrk.Events)-1) {
+				t.Errorf("%s: track %d has End of Track as event %d of %d", name, i, j, len(mtrk.Events))
+			}
+		}
+		if end := mtrk.Events[len(mtrk.Events)-1].Common().AbsTick; end != 192 {
+			t.Errorf("%s: track %d ends at tick %d, want 192", name, i, end)
+		}
+	}
+}
+
+func findFormatTestLyric(t *testing.T, name string, seq *Sequence) (int, *MetaEventLyric) {
+	for i, mtrk := range seq.Tracks {
+		for _, event := range mtrk.Events {
+			if lyric, ok := event.(*MetaEventLyric); ok {
+				if lyric.Channel != 2 || lyric.AbsTick != 48 {
+					t.Errorf("%s: lyric moved to channel %d at tick %d, want channel 2 at tick 48", name, lyric.Channel, lyric.AbsTick)
+				}
+				return i, lyric
+			}
+		}
+	}
+	t.Errorf("%s: lyric is lost", name)
+	return -1, nil
+}
+
+// Encodes and decodes the sequence again
+func reencodeFormatTestSequence(t *testing.T, seq *Sequence) *Sequence {
+	var buf bytes.Buffer
+	err := seq.EncodeSMF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err = DecodeSequenceFromSMF(bytes.NewReader(buf.Bytes()), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq
+}
+
+func TestConvertToFormat0(t *testing.T) {
+	seq := decodeFormatTestSequence(t)
+	err := seq.ConvertToFormat0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq.Header.Format != 0 || seq.Header.NTrks != 1 || len(seq.Tracks) != 1 {
+		t.Fatalf("got format %d with %d tracks", seq.Header.Format, len(seq.Tracks))
+	}
+	if len(seq.Tracks[0].Events) != 10 {
+		t.Errorf("got %d events, want 10", len(seq.Tracks[0].Events))
+	}
+	checkFormatTestTracks(t, "format 0", seq)
+	findFormatTestLyric(t, "format 0", seq)
+
+	seq = reencodeFormatTestSequence(t, seq)
+	checkFormatTestTracks(t, "format 0 encoded", seq)
+	findFormatTestLyric(t, "format 0 encoded", seq)
+}
+
+func TestConvertToFormat1(t *testing.T) {
+	seq := decodeFormatTestSequence(t)
+	err := seq.ConvertToFormat1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The conductor track, then channels 1, 2 and 3
+	if seq.Header.Format != 1 || seq.Header.NTrks != 4 || len(seq.Tracks) != 4 {
+		t.Fatalf("got format %d with %d tracks", seq.Header.Format, len(seq.Tracks))
+	}
+	for i, mtrk := range seq.Tracks {
+		for _, event := range mtrk.Events {
+			if isChannelEvent(event) && int(event.Common().Channel) != i {
+				t.Errorf("track %d has an event of channel %d", i, event.Common().Channel)
+			}
+		}
+	}
+	checkFormatTestTracks(t, "format 1", seq)
+	if track, _ := findFormatTestLyric(t, "format 1", seq); track != 2 {
+		t.Errorf("lyric is in track %d, want the channel 2 track", track)
+	}
+
+	seq = reencodeFormatTestSequence(t, seq)
+	checkFormatTestTracks(t, "format 1 encoded", seq)
+	if track, _ := findFormatTestLyric(t, "format 1 encoded", seq); track != 2 {
+		t.Errorf("encoded lyric is in track %d, want the channel 2 track", track)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"time"
+)
+
+type TimedEvent struct {
+	Track   int
+	Event   Event
+	AbsTick int64
+	Time    time.Duration
+}
+
+// EventIterator walks through all tracks of a sequence in playback order.
+// Events at the same tick are ordered by track index, then by their order in
+// the track. Format 2 sequences are walked track by track, since each track is
+// an independent pattern.
+type EventIterator struct {
+	seq     *Sequence
+	index   []int
+	absTick []int64
+	track   int
+}
+
+func (seq *Sequence) EventIterator() *EventIterator {
+	for _, mtrk := range seq.Tracks {
+		if mtrk.TempoTable == nil {
+			seq.CalculateTempoTable()
+			break
+		}
+	}
+	return newEventIterator(seq)
+}
+
+func newEventIterator(seq *Sequence) *EventIterator {
+	return &EventIterator{
+		seq:     seq,
+		index:   make([]int, len(seq.Tracks)),
+		absTick: make([]int64, len(seq.Tracks)),
+	}
+}
+
+func (it *EventIterator) Next() (TimedEvent, bool) {
+	track, event, absTick, ok := it.next()
+	if !ok {
+		return TimedEvent{}, false
+	}
+	return TimedEvent{
+		Track:   track,
+		Event:   event,
+		AbsTick: absTick,
+		Time:    it.seq.Tracks[track].ConvertAbsTickToDuration(absTick),
+	}, true
+}
+
+func (it *EventIterator) next() (track int, event Event, absTick int64, ok bool) {
+	if it.seq.Header != nil && it.seq.Header.Format == 2 {
+		for ; it.track < len(it.seq.Tracks); it.track++ {
+			if it.index[it.track] < len(it.seq.Tracks[it.track].Events) {
+				track = it.track
+				break
+			}
+		}
+		if it.track >= len(it.seq.Tracks) {
+			return 0, nil, 0, false
+		}
+	} else {
+		track = -1
+		for t, mtrk := range it.seq.Tracks {
+			if it.index[t] >= len(mtrk.Events) {
+				continue
+			}
+			tick := it.absTick[t] + int64(mtrk.Events[it.index[t]].Common().DeltaTick)
+			if track < 0 || tick < absTick {
+				track = t
+				absTick = tick
+			}
+		}
+		if track < 0 {
+			return 0, nil, 0, false
+		}
+	}
+	event = it.seq.Tracks[track].Events[it.index[track]]
+	absTick = it.absTick[track] + int64(event.Common().DeltaTick)
+	it.absTick[track] = absTick
+	it.index[track]++
+	return track, event, absTick, true
+}
@@ -822,6 +822,12 @@ func (ev *MetaEventSequencerSpecific) EncodeRealtime() ([]byte, error) {
 }
 
 func (ev *MetaEventSequencerSpecific) EncodeXML() *etree.Element {
+	if xf := decodeExactXFMeta(ev.Data); xf != nil {
+		el := etree.NewElement(xf.xfTag())
+		ev.encodeCommonXMLAttr(el)
+		xf.encodeXMLAttr(el)
+		return el
+	}
 	el := etree.NewElement("Meta")
 	ev.encodeCommonXMLAttr(el)
 	el.CreateAttr("type", fmt.Sprintf("%#02x", ev.MetaType()))
@@ -0,0 +1,166 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+	"sort"
+)
+
+// A meter change that does not fall on a bar line starts a new bar.
+func (seq *Sequence) CalculateMeterTable() {
+	var table *MeterTable
+	for i, mtrk := range seq.Tracks {
+		if i == 0 || seq.Header.Format == 2 {
+			if table != nil {
+				table.calculateBars()
+			}
+			table = &MeterTable{
+				Framerate: seq.Header.Framerate,
+				Division:  seq.Header.Division,
+			}
+		}
+		absTick := int64(0)
+		for _, event := range mtrk.Events {
+			absTick += int64(event.Common().DeltaTick)
+			timeSignature, ok := event.(*MetaEventTimeSignature)
+			if !ok || timeSignature.Numerator == 0 {
+				continue
+			}
+			change := MeterChange{
+				AbsTick:      absTick,
+				FilePosition: timeSignature.FilePosition,
+				Numerator:    timeSignature.Numerator,
+				Denominator:  timeSignature.Denominator,
+			}
+			if len(table.Changes) == 0 || table.Changes[len(table.Changes)-1].AbsTick != absTick {
+				table.Changes = append(table.Changes, change)
+			} else {
+				table.Changes[len(table.Changes)-1] = change
+			}
+		}
+		mtrk.MeterTable = table
+	}
+	if table != nil {
+		if seq.Header.Format != 2 {
+			sort.Slice(table.Changes, func(i, j int) bool {
+				return table.Changes[i].AbsTick < table.Changes[j].AbsTick || (table.Changes[i].AbsTick == table.Changes[j].AbsTick && table.Changes[i].FilePosition < table.Changes[j].FilePosition)
+			})
+		}
+		table.calculateBars()
+	}
+}
+
+func (mtrk *MTrk) ConvertAbsTickToPosition(absTick int64) MusicalPosition {
+	if mtrk.MeterTable == nil {
+		panic(errors.New("midimark: track does not contain a meter table"))
+	}
+	return mtrk.MeterTable.ConvertAbsTickToPosition(absTick)
+}
+
+func (mtrk *MTrk) ConvertPositionToAbsTick(pos MusicalPosition) int64 {
+	if mtrk.MeterTable == nil {
+		panic(errors.New("midimark: track does not contain a meter table"))
+	}
+	return mtrk.MeterTable.ConvertPositionToAbsTick(pos)
+}
+
+func (table *MeterTable) ConvertAbsTickToPosition(absTick int64) MusicalPosition {
+	change := table.changeAtTick(absTick)
+	barTicks, beatTicks := table.barTicks(change), table.beatTicks(change)
+	elapsed := absTick - change.AbsTick
+	bars := elapsed / barTicks
+	if elapsed%barTicks < 0 {
+		bars--
+	}
+	elapsed -= bars * barTicks
+	return MusicalPosition{
+		Bar:  change.Bar + bars,
+		Beat: elapsed/beatTicks + 1,
+		Tick: elapsed % beatTicks,
+	}
+}
+
+func (table *MeterTable) ConvertPositionToAbsTick(pos MusicalPosition) int64 {
+	change := MeterChange{Numerator: 4, Denominator: 2, Bar: 1}
+	for i := 0; i < len(table.Changes) && table.Changes[i].Bar <= pos.Bar; i++ {
+		change = table.Changes[i]
+	}
+	return change.AbsTick + (pos.Bar-change.Bar)*table.barTicks(change) + (pos.Beat-1)*table.beatTicks(change) + pos.Tick
+}
+
+func (table *MeterTable) calculateBars() {
+	prev := MeterChange{Numerator: 4, Denominator: 2, Bar: 1}
+	for i := range table.Changes {
+		change := &table.Changes[i]
+		change.Bar = prev.Bar
+		if elapsed := change.AbsTick - prev.AbsTick; elapsed > 0 {
+			barTicks := table.barTicks(prev)
+			change.Bar += (elapsed + barTicks - 1) / barTicks
+		}
+		prev = *change
+	}
+}
+
+func (table *MeterTable) changeAtTick(absTick int64) MeterChange {
+	change := MeterChange{Numerator: 4, Denominator: 2, Bar: 1}
+	for i := 0; i < len(table.Changes) && table.Changes[i].AbsTick <= absTick; i++ {
+		change = table.Changes[i]
+	}
+	return change
+}
+
+func (table *MeterTable) ticksPerQuarter() int64 {
+	return ticksPerQuarter(table.Framerate, table.Division)
+}
+
+func ticksPerQuarter(framerate uint8, division uint16) int64 {
+	// SMPTE based timing has no tempo, treat one second as a quarter note
+	switch framerate {
+	case 0:
+		return int64(division)
+	case 29:
+		return int64(division) * 30
+	default:
+		return int64(division) * int64(framerate)
+	}
+}
+
+func (table *MeterTable) beatTicks(change MeterChange) int64 {
+	beatTicks := table.ticksPerQuarter() * 4
+	if change.Denominator < 63 {
+		beatTicks >>= change.Denominator
+	} else {
+		beatTicks = 0
+	}
+	if beatTicks <= 0 {
+		return 1
+	}
+	return beatTicks
+}
+
+func (table *MeterTable) barTicks(change MeterChange) int64 {
+	return table.beatTicks(change) * int64(change.Numerator)
+}
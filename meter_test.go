@@ -0,0 +1,101 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMeterTable(t *testing.T) {
+	// 4/4 from the start, 7/8 from the second beat of bar 3, at 480 ticks per quarter note
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x01, 0xe0}
+	data = append(data, buildTestMTrk(
+		0x00, 0xff, 0x58, 0x04, 0x04, 0x02, 0x18, 0x08,
+		0xa1, 0x60, 0xff, 0x58, 0x04, 0x07, 0x03, 0x0c, 0x08,
+		0x00, 0xff, 0x2f, 0x00,
+	)...)
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := seq.Tracks[0].MeterTable
+	want := []MeterChange{
+		{AbsTick: 0, FilePosition: 0x16, Numerator: 4, Denominator: 2, Bar: 1},
+		// The meter change in the middle of bar 3 starts bar 4
+		{AbsTick: 4320, FilePosition: 0x1e, Numerator: 7, Denominator: 3, Bar: 4},
+	}
+	if len(table.Changes) != len(want) {
+		t.Fatalf("got changes %+v, want %+v", table.Changes, want)
+	}
+	for i := range want {
+		if table.Changes[i] != want[i] {
+			t.Errorf("change %d: got %+v, want %+v", i, table.Changes[i], want[i])
+		}
+	}
+
+	positions := []struct {
+		absTick  int64
+		position string
+	}{
+		{0, "1:1:0"},
+		{1920, "2:1:0"},
+		{4319, "3:1:479"},
+		{4320, "4:1:0"},
+		{4320 + 3*240 + 10, "4:4:10"},
+		{4320 + 1680, "5:1:0"},
+		{4320 + 8*1680 + 2*240 + 120, "12:3:120"},
+	}
+	for _, test := range positions {
+		pos := table.ConvertAbsTickToPosition(test.absTick)
+		if pos.String() != test.position {
+			t.Errorf("tick %d: got %s, want %s", test.absTick, pos, test.position)
+		}
+		parsed, err := ParseMusicalPosition(test.position)
+		if err != nil {
+			t.Errorf("%s: %v", test.position, err)
+			continue
+		}
+		if got := table.ConvertPositionToAbsTick(parsed); got != test.absTick {
+			t.Errorf("%s: got tick %d, want %d", test.position, got, test.absTick)
+		}
+	}
+
+	// A tick count as long as a beat carries over to the next beat
+	pos, err := ParseMusicalPosition("12:3:240")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != (MusicalPosition{Bar: 12, Beat: 3, Tick: 240}) {
+		t.Errorf("parsed to %+v", pos)
+	}
+	absTick := table.ConvertPositionToAbsTick(pos)
+	if absTick != 4320+8*1680+3*240 {
+		t.Errorf("12:3:240: got tick %d, want %d", absTick, 4320+8*1680+3*240)
+	}
+	if got := table.ConvertAbsTickToPosition(absTick).String(); got != "12:4:0" {
+		t.Errorf("tick %d: got %s, want 12:4:0", absTick, got)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+// A device ID of 7F addresses every device
+const SysExAllCall uint8 = 0x7f
+
+type MMCCommand uint8
+
+const (
+	MMCStop         MMCCommand = 0x01
+	MMCPlay         MMCCommand = 0x02
+	MMCDeferredPlay MMCCommand = 0x03
+	MMCFastForward  MMCCommand = 0x04
+	MMCRewind       MMCCommand = 0x05
+	MMCRecordStrobe MMCCommand = 0x06
+	MMCRecordExit   MMCCommand = 0x07
+	MMCRecordPause  MMCCommand = 0x08
+	MMCPause        MMCCommand = 0x09
+	MMCEject        MMCCommand = 0x0a
+	MMCChase        MMCCommand = 0x0b
+	MMCReset        MMCCommand = 0x0d
+	MMCLocate       MMCCommand = 0x44
+)
+
+var mmcCommandToTag = map[MMCCommand]string{
+	MMCStop:         "MMCStop",
+	MMCPlay:         "MMCPlay",
+	MMCDeferredPlay: "MMCDeferredPlay",
+	MMCFastForward:  "MMCFastForward",
+	MMCRewind:       "MMCRewind",
+	MMCRecordStrobe: "MMCRecordStrobe",
+	MMCRecordExit:   "MMCRecordExit",
+	MMCRecordPause:  "MMCRecordPause",
+	MMCPause:        "MMCPause",
+	MMCEject:        "MMCEject",
+	MMCChase:        "MMCChase",
+	MMCReset:        "MMCReset",
+}
+
+// Universal Realtime 7F dev 06 cmd, for commands without data.
+// Commands not listed above are written as an MMC tag with a command attribute.
+type SysExMMC struct {
+	DeviceID uint8
+	Command  MMCCommand
+}
+
+// Universal Realtime 7F dev 06 44 06 01 hr mn sc fr ff
+type SysExMMCLocate struct {
+	DeviceID  uint8
+	Target    SMPTETimecode
+	Subframes uint8
+}
+
+// Reports whether a message sent to target should be handled by a device
+func MatchSysExDevice(target, deviceID uint8) bool {
+	return target == SysExAllCall || target == deviceID
+}
+
+// Decodes the MMC command in body, which excludes F7
+func decodeMMC(body []byte) (SysEx, error) {
+	command := MMCCommand(body[3])
+	if len(body) == 4 && command != MMCLocate {
+		return &SysExMMC{DeviceID: body[1], Command: command}, nil
+	}
+	if command == MMCLocate && len(body) == 11 && body[4] == 0x06 && body[5] == 0x01 {
+		return &SysExMMCLocate{
+			DeviceID: body[1],
+			Target: SMPTETimecode{
+				Framerate: mtcRateToFramerate[(body[6]>>5)&0x3],
+				Hours:     body[6] & 0x1f,
+				Minutes:   body[7] & 0x3f,
+				Seconds:   body[8] & 0x3f,
+				Frames:    body[9] & 0x1f,
+			},
+			Subframes: body[10] & 0x7f,
+		}, nil
+	}
+	return nil, nil
+}
+
+func decodeMMCFromXML(el *etree.Element) (SysEx, error) {
+	invalid := func(name string) error {
+		return newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+	}
+	deviceID, err := strconv.ParseUint(el.SelectAttrValue("device", ""), 0, 7)
+	if err != nil {
+		return nil, invalid("device")
+	}
+	switch el.Tag {
+	case "MMC":
+		command, err := strconv.ParseUint(el.SelectAttrValue("command", ""), 0, 7)
+		if err != nil || MMCCommand(command) == MMCLocate {
+			return nil, invalid("command")
+		}
+		return &SysExMMC{DeviceID: uint8(deviceID), Command: MMCCommand(command)}, nil
+	case "MMCLocate":
+		framerate, err := strconv.ParseUint(el.SelectAttrValue("framerate", ""), 0, 8)
+		if _, ok := framerateToMTCRate[uint8(framerate)]; err != nil || !ok {
+			return nil, invalid("framerate")
+		}
+		sysex := &SysExMMCLocate{
+			DeviceID: uint8(deviceID),
+			Target: SMPTETimecode{
+				Framerate: uint8(framerate),
+			},
+		}
+		_, err = fmt.Sscanf(el.SelectAttrValue("timecode", ""), "%d:%d:%d:%d.%d", &sysex.Target.Hours, &sysex.Target.Minutes, &sysex.Target.Seconds, &sysex.Target.Frames, &sysex.Subframes)
+		if err != nil {
+			return nil, invalid("timecode")
+		}
+		return sysex, nil
+	}
+	for command, tag := range mmcCommandToTag {
+		if tag == el.Tag {
+			return &SysExMMC{DeviceID: uint8(deviceID), Command: command}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (sysex *SysExMMC) SysExData() []byte {
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x06, uint8(sysex.Command) & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMMC) sysexTag() string {
+	if tag, ok := mmcCommandToTag[sysex.Command]; ok {
+		return tag
+	}
+	return "MMC"
+}
+
+func (sysex *SysExMMC) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	if _, ok := mmcCommandToTag[sysex.Command]; !ok {
+		el.CreateAttr("command", fmt.Sprintf("%#02x", uint8(sysex.Command)))
+	}
+}
+
+func (sysex *SysExMMCLocate) SysExData() []byte {
+	tc := sysex.Target
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x06, uint8(MMCLocate), 0x06, 0x01, framerateToMTCRate[tc.Framerate]<<5 | tc.Hours&0x1f, tc.Minutes & 0x3f, tc.Seconds & 0x3f, tc.Frames & 0x1f, sysex.Subframes & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMMCLocate) sysexTag() string {
+	return "MMCLocate"
+}
+
+func (sysex *SysExMMCLocate) encodeXMLAttr(el *etree.Element) {
+	tc := sysex.Target
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("framerate", fmt.Sprintf("%d", tc.Framerate))
+	el.CreateAttr("timecode", fmt.Sprintf("%02d:%02d:%02d:%02d.%02d", tc.Hours, tc.Minutes, tc.Seconds, tc.Frames, sysex.Subframes))
+}
@@ -0,0 +1,49 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import "testing"
+
+func TestMMCRoundTrip(t *testing.T) {
+	tests := []struct {
+		sysex SysEx
+		data  []byte
+	}{
+		{&SysExMMC{DeviceID: SysExAllCall, Command: MMCPlay}, []byte{0x7f, 0x7f, 0x06, 0x02, 0xf7}},
+		{&SysExMMC{DeviceID: 0x10, Command: MMCStop}, []byte{0x7f, 0x10, 0x06, 0x01, 0xf7}},
+		{&SysExMMC{DeviceID: 0x10, Command: 0x40}, []byte{0x7f, 0x10, 0x06, 0x40, 0xf7}},
+		{
+			&SysExMMCLocate{DeviceID: 0x10, Target: SMPTETimecode{Framerate: 29, Hours: 1, Minutes: 2, Seconds: 3, Frames: 4}, Subframes: 5},
+			[]byte{0x7f, 0x10, 0x06, 0x44, 0x06, 0x01, 0x41, 0x02, 0x03, 0x04, 0x05, 0xf7},
+		},
+		{
+			&SysExMMCLocate{DeviceID: SysExAllCall, Target: SMPTETimecode{Framerate: 25, Hours: 23, Minutes: 59, Seconds: 59, Frames: 24}, Subframes: 99},
+			[]byte{0x7f, 0x7f, 0x06, 0x44, 0x06, 0x01, 0x37, 0x3b, 0x3b, 0x18, 0x63, 0xf7},
+		},
+	}
+	for _, test := range tests {
+		checkSysExRoundTrip(t, test.sysex, test.data)
+	}
+}
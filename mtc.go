@@ -0,0 +1,304 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidFramerate = errors.New("midimark: invalid SMPTE framerate")
+
+// Framerate is 24, 25, 29 (29.97 drop frame) or 30
+type SMPTETimecode struct {
+	Framerate uint8
+	Hours     uint8
+	Minutes   uint8
+	Seconds   uint8
+	Frames    uint8
+}
+
+var (
+	mtcRateToFramerate = [4]uint8{24, 25, 29, 30}
+	framerateToMTCRate = map[uint8]uint8{24: 0, 25: 1, 29: 2, 30: 3}
+)
+
+// Returns the timecode of the frame at or before a duration
+func NewSMPTETimecode(duration time.Duration, framerate uint8) (SMPTETimecode, error) {
+	if _, ok := framerateToMTCRate[framerate]; !ok {
+		return SMPTETimecode{}, ErrInvalidFramerate
+	}
+	if framerate == 29 {
+		// 30000 frames last exactly 1001 seconds, split the value to avoid overflow
+		unit := int64(time.Second * 1001)
+		return newSMPTETimecodeFromFrameCount(int64(duration)/unit*30000+int64(duration)%unit*30000/unit, framerate), nil
+	}
+	return newSMPTETimecodeFromFrameCount(int64(duration)*int64(framerate)/int64(time.Second), framerate), nil
+}
+
+func newSMPTETimecodeFromFrameCount(frames int64, framerate uint8) SMPTETimecode {
+	if frames < 0 {
+		frames = 0
+	}
+	fps := int64(framerate)
+	if framerate == 29 {
+		// Drop frame numbers 0 and 1 at the start of every minute, except every tenth minute
+		fps = 30
+		tenMinutes, remainder := frames/17982, frames%17982
+		frames += 18 * tenMinutes
+		if remainder >= 2 {
+			frames += 2 * ((remainder - 2) / 1798)
+		}
+	}
+	return SMPTETimecode{
+		Framerate: framerate,
+		Hours:     uint8(frames / (fps * 3600) % 24),
+		Minutes:   uint8(frames / (fps * 60) % 60),
+		Seconds:   uint8(frames / fps % 60),
+		Frames:    uint8(frames % fps),
+	}
+}
+
+// Returns the number of frames since 00:00:00:00
+func (tc SMPTETimecode) FrameCount() int64 {
+	fps := int64(tc.Framerate)
+	if tc.Framerate == 29 {
+		fps = 30
+	}
+	frames := ((int64(tc.Hours)*60+int64(tc.Minutes))*60+int64(tc.Seconds))*fps + int64(tc.Frames)
+	if tc.Framerate == 29 {
+		totalMinutes := int64(tc.Hours)*60 + int64(tc.Minutes)
+		frames -= 2 * (totalMinutes - totalMinutes/10)
+	}
+	return frames
+}
+
+func (tc SMPTETimecode) Duration() time.Duration {
+	return smpteQuarterFrameTime(tc.FrameCount()*4, tc.Framerate)
+}
+
+// Returns the timecode a number of frames later, or earlier if negative
+func (tc SMPTETimecode) AddFrames(frames int64) SMPTETimecode {
+	return newSMPTETimecodeFromFrameCount(tc.FrameCount()+frames, tc.Framerate)
+}
+
+func (tc SMPTETimecode) String() string {
+	if tc.Framerate == 29 {
+		return fmt.Sprintf("%02d:%02d:%02d;%02d", tc.Hours, tc.Minutes, tc.Seconds, tc.Frames)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", tc.Hours, tc.Minutes, tc.Seconds, tc.Frames)
+}
+
+func (tc SMPTETimecode) SMPTEOffset() *MetaEventSMPTEOffset {
+	return &MetaEventSMPTEOffset{
+		Framerate: tc.Framerate,
+		Hours:     tc.Hours,
+		Minutes:   tc.Minutes,
+		Seconds:   tc.Seconds,
+		Frames:    tc.Frames,
+	}
+}
+
+// Returns the timecode of the offset, ignoring the fractional frames.
+// Timecodes can not be negative, as MTC and MMC have no way to send the sign,
+// so a negative offset returns its magnitude and callers check Negative.
+func (ev *MetaEventSMPTEOffset) Timecode() SMPTETimecode {
+	return SMPTETimecode{
+		Framerate: ev.Framerate,
+		Hours:     ev.Hours,
+		Minutes:   ev.Minutes,
+		Seconds:   ev.Seconds,
+		Frames:    ev.Frames,
+	}
+}
+
+// Builds a Universal Realtime MTC Full Message, used to locate devices
+func NewMTCFullFrame(tc SMPTETimecode, deviceID uint8) (*EventSystemExclusive, error) {
+	rate, ok := framerateToMTCRate[tc.Framerate]
+	if !ok {
+		return nil, ErrInvalidFramerate
+	}
+	return &EventSystemExclusive{
+		Data: []byte{0x7f, deviceID & 0x7f, 0x01, 0x01, rate<<5 | tc.Hours&0x1f, tc.Minutes & 0x3f, tc.Seconds & 0x3f, tc.Frames & 0x1f, 0xf7},
+	}, nil
+}
+
+// Returns the time of a number of quarter frames since 00:00:00:00, rounded up
+// so that it converts back to the same frame
+func smpteQuarterFrameTime(quarterFrames int64, framerate uint8) time.Duration {
+	if framerate == 29 {
+		// Split the value to avoid overflow on long durations
+		return time.Duration(quarterFrames/120000)*1001*time.Second + time.Duration((quarterFrames%120000*int64(time.Second*1001)+119999)/120000)
+	}
+	return time.Duration((quarterFrames*int64(time.Second) + int64(framerate)*4 - 1) / (int64(framerate) * 4))
+}
+
+// MTCDecoder reassembles MIDI Time Code from quarter frame messages and full
+// frame messages.
+// A timecode is reported once all eight quarter frames have been received in
+// order, compensating for the two frames it took to transmit them.
+type MTCDecoder struct {
+	values    [8]uint8
+	received  uint8
+	lastType  uint8
+	direction int
+}
+
+func NewMTCDecoder() *MTCDecoder {
+	return &MTCDecoder{
+		lastType: 0xff,
+	}
+}
+
+// Returns 1 when time code runs forward, -1 when backward, or 0 if unknown
+func (d *MTCDecoder) Direction() int {
+	return d.direction
+}
+
+func (d *MTCDecoder) Reset() {
+	d.received = 0
+	d.lastType = 0xff
+	d.direction = 0
+}
+
+func (d *MTCDecoder) DecodeQuarterFrame(ev *EventTimeCodeQuarterFrame) (tc SMPTETimecode, ok bool) {
+	messageType := ev.MessageType & 0x7
+	direction := 0
+	if d.lastType < 8 {
+		switch messageType {
+		case (d.lastType + 1) % 8:
+			direction = 1
+		case (d.lastType + 7) % 8:
+			direction = -1
+		}
+	}
+	if direction == 0 || direction != d.direction && d.direction != 0 {
+		d.received = 0
+	}
+	d.direction = direction
+	d.lastType = messageType
+	d.values[messageType] = ev.Values & 0xf
+	d.received |= 1 << messageType
+
+	if d.received != 0xff || (direction > 0 && messageType != 7) || (direction < 0 && messageType != 0) {
+		return SMPTETimecode{}, false
+	}
+	tc = SMPTETimecode{
+		Framerate: mtcRateToFramerate[(d.values[7]>>1)&0x3],
+		Hours:     d.values[6] | (d.values[7]&0x1)<<4,
+		Minutes:   d.values[4] | (d.values[5]&0x3)<<4,
+		Seconds:   d.values[2] | (d.values[3]&0x3)<<4,
+		Frames:    d.values[0] | (d.values[1]&0x1)<<4,
+	}
+	return tc.AddFrames(int64(2 * direction)), true
+}
+
+// Decodes a Universal Realtime MTC Full Message, which also resets quarter frame decoding
+func (d *MTCDecoder) DecodeFullFrame(ev *EventSystemExclusive) (tc SMPTETimecode, ok bool) {
+	data := ev.Data
+	if len(data) < 8 || data[0] != 0x7f || data[2] != 0x01 || data[3] != 0x01 {
+		return SMPTETimecode{}, false
+	}
+	d.Reset()
+	return SMPTETimecode{
+		Framerate: mtcRateToFramerate[(data[4]>>5)&0x3],
+		Hours:     data[4] & 0x1f,
+		Minutes:   data[5] & 0x3f,
+		Seconds:   data[6] & 0x3f,
+		Frames:    data[7] & 0x1f,
+	}, true
+}
+
+// MTCGenerator produces the quarter frame messages of a running time code
+type MTCGenerator struct {
+	Framerate  uint8
+	startFrame int64
+	quarter    int64
+}
+
+// Starts the time code at the first frame at or after a duration
+func NewMTCGenerator(start time.Duration, framerate uint8) (*MTCGenerator, error) {
+	g := &MTCGenerator{
+		Framerate: framerate,
+	}
+	err := g.Locate(start)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Moves the time code to the first frame at or after a duration
+func (g *MTCGenerator) Locate(position time.Duration) error {
+	tc, err := NewSMPTETimecode(position, g.Framerate)
+	if err != nil {
+		return err
+	}
+	g.startFrame = tc.FrameCount()
+	if tc.Duration() < position {
+		g.startFrame++
+	}
+	g.quarter = 0
+	return nil
+}
+
+// Returns the full frame message for the position of the last Locate
+func (g *MTCGenerator) FullFrame(deviceID uint8) (*EventSystemExclusive, error) {
+	return NewMTCFullFrame(newSMPTETimecodeFromFrameCount(g.startFrame, g.Framerate), deviceID)
+}
+
+// Returns the next quarter frame message and when to send it.
+// Each cycle of eight quarter frames spans two frames and carries the
+// timecode of the frame it started at.
+func (g *MTCGenerator) Next() (time.Duration, *EventTimeCodeQuarterFrame) {
+	at := smpteQuarterFrameTime(g.startFrame*4+g.quarter, g.Framerate)
+	messageType := uint8(g.quarter % 8)
+	tc := newSMPTETimecodeFromFrameCount(g.startFrame+g.quarter/8*2, g.Framerate)
+	var values uint8
+	switch messageType {
+	case 0:
+		values = tc.Frames & 0xf
+	case 1:
+		values = tc.Frames >> 4 & 0x1
+	case 2:
+		values = tc.Seconds & 0xf
+	case 3:
+		values = tc.Seconds >> 4 & 0x3
+	case 4:
+		values = tc.Minutes & 0xf
+	case 5:
+		values = tc.Minutes >> 4 & 0x3
+	case 6:
+		values = tc.Hours & 0xf
+	case 7:
+		values = framerateToMTCRate[g.Framerate]<<1 | tc.Hours>>4&0x1
+	}
+	g.quarter++
+	return at, &EventTimeCodeQuarterFrame{
+		MessageType: messageType,
+		Values:      values,
+	}
+}
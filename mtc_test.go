@@ -0,0 +1,50 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDropFrameTimingAgrees(t *testing.T) {
+	table := &TempoTable{Framerate: 29, Division: 80}
+	// Up to 24 hours of frames, where 2997/100 and 30000/1001 differ by seconds
+	for _, frames := range []int64{0, 1, 1799, 17982, 107892, 2589407} {
+		absTick := frames * int64(table.Division)
+		duration := table.ConvertAbsTickToDuration(absTick)
+		timecodeDuration := newSMPTETimecodeFromFrameCount(frames, 29).Duration()
+		if diff := timecodeDuration - duration; diff < 0 || diff > time.Nanosecond {
+			t.Errorf("frame %d: tempo table gives %v, timecode gives %v", frames, duration, timecodeDuration)
+		}
+		if tick := table.ConvertDurationToAbsTick(duration); tick != absTick {
+			t.Errorf("frame %d: %v converts back to tick %d, want %d", frames, duration, tick, absTick)
+		}
+		tc, err := NewSMPTETimecode(timecodeDuration, 29)
+		if err != nil || tc.FrameCount() != frames {
+			t.Errorf("frame %d: %v converts back to timecode %v", frames, timecodeDuration, tc)
+		}
+	}
+}
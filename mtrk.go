@@ -77,39 +77,18 @@ func (mtrk *MTrk) EncodeXML() *etree.Element {
 	return el
 }
 
+// Chunks of other types before the track are skipped with a warning
 func DecodeMTrkFromSMF(r io.ReadSeeker, warningCallback WarningCallback) (mtrk *MTrk, err error) {
-	pos := tell(r)
-	var buf [8]byte
-
-	_, err = io.ReadFull(r, buf[:4])
-	if err != nil {
-		return
-	}
-	if !bytes.Equal(buf[:4], []byte{'M', 'T', 'r', 'k'}) {
-		warningCallback(newSMFDecodeError(pos, errors.New("invalid MTrk chunk")))
-		for {
-			pos, err = r.Seek(-3, io.SeekCurrent)
-			if err != nil {
-				return
-			}
-			_, err = io.ReadFull(r, buf[:4])
-			if err != nil {
-				return
-			}
-			if bytes.Equal(buf[:4], []byte{'M', 'T', 'r', 'k'}) {
-				break
-			}
-		}
-	}
+	return decodeMTrkFromSMF(r, warningCallback, func(chunk *Chunk) {
+		warningCallback(newSMFDecodeError(chunk.FilePosition, fmt.Errorf("invalid MTrk chunk, skipped %q chunk", chunk.Type[:])))
+	})
+}
 
-	_, err = io.ReadFull(r, buf[4:8])
+func decodeMTrkFromSMF(r io.ReadSeeker, warningCallback WarningCallback, chunkCallback func(chunk *Chunk)) (mtrk *MTrk, err error) {
+	pos, length, err := decodeMTrkHeader(r, warningCallback, chunkCallback)
 	if err != nil {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
-		}
 		return
 	}
-	length := binary.BigEndian.Uint32(buf[4:8])
 
 	mtrk = &MTrk{
 		FilePosition: pos,
@@ -120,68 +99,149 @@ func DecodeMTrkFromSMF(r io.ReadSeeker, warningCallback WarningCallback) (mtrk *
 
 	defer mtrk.ConvertDeltaToAbsTick()
 
-	// Strangely there are wild MIDI files with MTrk length == 0
-	if length == 0 {
-		warningCallback(newSMFDecodeError(pos+4, errors.New("MIDI track seems to contain no events")))
-	} else {
-		r, err = newLimitReadSeeker(r, int64(length))
+	r, err = newMTrkReadSeeker(r, pos, length, warningCallback)
+	if err != nil {
+		return
+	}
+
+	for {
+		var event Event
+		event, err = decodeMTrkEvent(r, pos, length, &status, &channel, warningCallback)
+		if event != nil {
+			mtrk.Events = append(mtrk.Events, event)
+		}
 		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
 			return
 		}
 	}
+}
 
-	for {
-		pos = tell(r)
+// Chunks of other types before the track are passed to chunkCallback
+func decodeMTrkHeader(r io.ReadSeeker, warningCallback WarningCallback, chunkCallback func(chunk *Chunk)) (pos int64, length uint32, err error) {
+	pos = tell(r)
+	var buf [8]byte
 
-		// If length == 0, guess the actual length by searching for the next track
-		if length == 0 {
-			_, err = io.ReadFull(r, buf[:4])
+	for {
+		_, err = io.ReadFull(r, buf[:4])
+		if err != nil {
+			return
+		}
+		if bytes.Equal(buf[:4], []byte{'M', 'T', 'r', 'k'}) {
+			break
+		}
+		valid := isChunkType(buf[:4])
+		if valid {
+			_, err = io.ReadFull(r, buf[4:8])
 			if err != nil {
 				if err == io.EOF {
-					err = nil
-					return
-				} else if err == io.ErrUnexpectedEOF {
-					_, err = r.Seek(pos, io.SeekStart)
-					if err != nil {
-						return
-					}
-				} else {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			// A chunk running past the end of file is more likely garbage.
+			// Streams can not tell where the file ends, so the length is
+			// trusted there, and a bogus one keeps the rest of the input as
+			// an incomplete chunk.
+			if remaining, ok := remainingLength(r); ok && int64(binary.BigEndian.Uint32(buf[4:8])) > remaining {
+				valid = false
+				_, err = r.Seek(pos+4, io.SeekStart)
+				if err != nil {
 					return
 				}
-			} else {
-				_, err = r.Seek(pos, io.SeekStart)
+			}
+		}
+		if !valid {
+			warningCallback(newSMFDecodeError(pos, errors.New("invalid MTrk chunk")))
+			for {
+				pos, err = r.Seek(-3, io.SeekCurrent)
 				if err != nil {
 					return
 				}
-				if bytes.Equal(buf[:], []byte{'M', 'T', 'r', 'k'}) {
+				_, err = io.ReadFull(r, buf[:4])
+				if err != nil {
 					return
 				}
+				if bytes.Equal(buf[:4], []byte{'M', 'T', 'r', 'k'}) {
+					break
+				}
 			}
+			break
 		}
+		var chunk *Chunk
+		chunk, err = decodeChunkFromSMF(r, pos, buf, warningCallback)
+		if chunk != nil && chunkCallback != nil {
+			chunkCallback(chunk)
+		}
+		if err != nil {
+			return
+		}
+		pos = tell(r)
+	}
 
-		var event Event
-		event, err = DecodeEventFromSMF(r, &status, &channel, warningCallback)
-		if event != nil {
-			mtrk.Events = append(mtrk.Events, event)
+	_, err = io.ReadFull(r, buf[4:8])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return
+	}
+	length = binary.BigEndian.Uint32(buf[4:8])
+	return
+}
+
+func newMTrkReadSeeker(r io.ReadSeeker, pos int64, length uint32, warningCallback WarningCallback) (io.ReadSeeker, error) {
+	// Strangely there are wild MIDI files with MTrk length == 0
+	if length == 0 {
+		warningCallback(newSMFDecodeError(pos+4, errors.New("MIDI track seems to contain no events")))
+		return r, nil
+	}
+	return newLimitReadSeeker(r, int64(length))
+}
+
+// Returns io.EOF when the track ends normally
+func decodeMTrkEvent(r io.ReadSeeker, mtrkPos int64, length uint32, status, channel *uint8, warningCallback WarningCallback) (event Event, err error) {
+	pos := tell(r)
+
+	// If length == 0, guess the actual length by searching for the next track
+	if length == 0 {
+		var buf [4]byte
+		_, err = io.ReadFull(r, buf[:])
 		if err != nil {
-			if err == io.EOF {
-				if length != 0 && pos < mtrk.FilePosition+int64(length) {
-					err = io.ErrUnexpectedEOF
-				} else {
-					err = nil
-				}
-			} else if err == io.ErrUnexpectedEOF {
-				if length == 0 || pos < mtrk.FilePosition+int64(length) {
-					err = io.ErrUnexpectedEOF
-				} else {
-					warningCallback(newSMFDecodeError(pos, errors.New("MIDI track is incomplete")))
-					err = nil
+			if err == io.ErrUnexpectedEOF {
+				_, err = r.Seek(pos, io.SeekStart)
+				if err != nil {
+					return
 				}
+			} else {
+				return
 			}
-			return
+		} else {
+			_, err = r.Seek(pos, io.SeekStart)
+			if err != nil {
+				return
+			}
+			if bytes.Equal(buf[:], []byte{'M', 'T', 'r', 'k'}) {
+				err = io.EOF
+				return
+			}
+		}
+	}
+
+	event, err = DecodeEventFromSMF(r, status, channel, warningCallback)
+	if err == io.EOF {
+		if length != 0 && pos < mtrkPos+int64(length) {
+			err = io.ErrUnexpectedEOF
+		}
+	} else if err == io.ErrUnexpectedEOF {
+		if length != 0 && pos >= mtrkPos+int64(length) {
+			warningCallback(newSMFDecodeError(pos, errors.New("MIDI track is incomplete")))
+			err = io.EOF
 		}
 	}
+	return
 }
 
 func DecodeMTrkFromXML(el *etree.Element) (*MTrk, error) {
@@ -0,0 +1,531 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// MTSNoteTuning is a frequency in MIDI Tuning Standard form: a semitone, plus
+// a fraction in units of 1/16384 semitone
+type MTSNoteTuning struct {
+	Semitone uint8
+	Fraction uint16
+}
+
+// Tells the receiver to leave the tuning of a key unchanged
+var MTSNoChange = MTSNoteTuning{Semitone: 0x7f, Fraction: 0x3fff}
+
+type MTSKeyTuning struct {
+	Key    Key
+	Tuning MTSNoteTuning
+}
+
+// Universal Non-Realtime 7E dev 08 01
+type SysExMTSBulkDump struct {
+	DeviceID uint8
+	Program  uint8
+	Name     string
+	Tunings  [128]MTSNoteTuning
+}
+
+// Universal Realtime 7F dev 08 02, or 08 07 when Banked, which is also
+// available as Non-Realtime 7E
+type SysExMTSNoteChange struct {
+	DeviceID uint8
+	Realtime bool
+	Banked   bool
+	Bank     uint8
+	Program  uint8
+	Changes  []MTSKeyTuning
+}
+
+// Universal 7E or 7F dev 08 08, offsets in cents from equal temperament,
+// from C to B, in -64..63
+type SysExMTSScaleOctave1Byte struct {
+	DeviceID uint8
+	Realtime bool
+	Channels uint16
+	Offsets  [12]int8
+}
+
+// Universal 7E or 7F dev 08 09, offsets in units of 100/8192 cents from equal
+// temperament, from C to B, in -8192..8191
+type SysExMTSScaleOctave2Byte struct {
+	DeviceID uint8
+	Realtime bool
+	Channels uint16
+	Offsets  [12]int16
+}
+
+// Returns the tuning nearest to a pitch in cents above C-1 (MIDI key 0)
+func NewMTSNoteTuning(cents float64) MTSNoteTuning {
+	units := math.Floor(cents*16384/100 + 0.5)
+	if units <= 0 || math.IsNaN(units) {
+		return MTSNoteTuning{}
+	}
+	if units >= 0x7f*16384+0x3fff {
+		// 7F 7F 7F is reserved for no change
+		return MTSNoteTuning{Semitone: 0x7f, Fraction: 0x3ffe}
+	}
+	return MTSNoteTuning{
+		Semitone: uint8(int64(units) / 16384),
+		Fraction: uint16(int64(units) % 16384),
+	}
+}
+
+// Returns the tuning nearest to a frequency in Hz, given that A4 is 440 Hz
+func NewMTSNoteTuningFromFrequency(frequency float64) MTSNoteTuning {
+	return NewMTSNoteTuning(6900 + 1200*math.Log2(frequency/440))
+}
+
+// Returns the pitch in cents above C-1 (MIDI key 0)
+func (tuning MTSNoteTuning) Cents() float64 {
+	return float64(tuning.Semitone)*100 + float64(tuning.Fraction)*100/16384
+}
+
+func (tuning MTSNoteTuning) Frequency() float64 {
+	return 440 * math.Exp2((tuning.Cents()-6900)/1200)
+}
+
+func (tuning MTSNoteTuning) String() string {
+	if tuning == MTSNoChange {
+		return "-"
+	}
+	return strconv.FormatFloat(tuning.Cents(), 'f', -1, 64)
+}
+
+func ParseMTSNoteTuning(str string) (MTSNoteTuning, error) {
+	if str == "-" {
+		return MTSNoChange, nil
+	}
+	cents, err := strconv.ParseFloat(str, 64)
+	if err != nil || cents < 0 || cents >= 12800 {
+		return MTSNoteTuning{}, fmt.Errorf("unrecognized tuning %q", str)
+	}
+	return NewMTSNoteTuning(cents), nil
+}
+
+func (tuning MTSNoteTuning) bytes() []byte {
+	return []byte{tuning.Semitone & 0x7f, uint8(tuning.Fraction>>7) & 0x7f, uint8(tuning.Fraction) & 0x7f}
+}
+
+func decodeMTSNoteTuning(data []byte) MTSNoteTuning {
+	return MTSNoteTuning{
+		Semitone: data[0] & 0x7f,
+		Fraction: uint16(data[1]&0x7f)<<7 | uint16(data[2]&0x7f),
+	}
+}
+
+func mtsChecksum(data []byte) uint8 {
+	sum := uint8(0)
+	for _, b := range data {
+		sum ^= b
+	}
+	return sum & 0x7f
+}
+
+// Decodes the MIDI Tuning Standard message in body, which excludes F7
+func decodeMTS(body []byte) (SysEx, error) {
+	realtime := body[0] == 0x7f
+	switch body[3] {
+	case 0x01:
+		if realtime || len(body) != 406 {
+			return nil, nil
+		}
+		if mtsChecksum(body[:405]) != body[405] {
+			return nil, ErrSysExChecksum
+		}
+		sysex := &SysExMTSBulkDump{
+			DeviceID: body[1],
+			Program:  body[4],
+			Name:     strings.TrimRight(string(body[5:21]), " "),
+		}
+		for i := range sysex.Tunings {
+			sysex.Tunings[i] = decodeMTSNoteTuning(body[21+i*3:])
+		}
+		return sysex, nil
+	case 0x02, 0x07:
+		sysex := &SysExMTSNoteChange{
+			DeviceID: body[1],
+			Realtime: realtime,
+			Banked:   body[3] == 0x07,
+		}
+		data := body[4:]
+		if sysex.Banked {
+			if len(data) == 0 {
+				return nil, nil
+			}
+			sysex.Bank = data[0]
+			data = data[1:]
+		}
+		if len(data) < 2 || len(data) != 2+int(data[1])*4 {
+			return nil, nil
+		}
+		sysex.Program = data[0]
+		sysex.Changes = make([]MTSKeyTuning, data[1])
+		for i := range sysex.Changes {
+			sysex.Changes[i] = MTSKeyTuning{
+				Key:    Key(data[2+i*4] & 0x7f),
+				Tuning: decodeMTSNoteTuning(data[3+i*4:]),
+			}
+		}
+		return sysex, nil
+	case 0x08:
+		if len(body) != 19 {
+			return nil, nil
+		}
+		sysex := &SysExMTSScaleOctave1Byte{
+			DeviceID: body[1],
+			Realtime: realtime,
+			Channels: decodeMTSChannels(body[4:7]),
+		}
+		for i := range sysex.Offsets {
+			sysex.Offsets[i] = int8(body[7+i]&0x7f) - 0x40
+		}
+		return sysex, nil
+	case 0x09:
+		if len(body) != 31 {
+			return nil, nil
+		}
+		sysex := &SysExMTSScaleOctave2Byte{
+			DeviceID: body[1],
+			Realtime: realtime,
+			Channels: decodeMTSChannels(body[4:7]),
+		}
+		for i := range sysex.Offsets {
+			sysex.Offsets[i] = (int16(body[7+i*2]&0x7f)<<7 | int16(body[8+i*2]&0x7f)) - 0x2000
+		}
+		return sysex, nil
+	}
+	return nil, nil
+}
+
+// Channel 1 is bit 0
+func decodeMTSChannels(data []byte) uint16 {
+	return uint16(data[0]&0x03)<<14 | uint16(data[1]&0x7f)<<7 | uint16(data[2]&0x7f)
+}
+
+func encodeMTSChannels(channels uint16) []byte {
+	return []byte{uint8(channels>>14) & 0x03, uint8(channels>>7) & 0x7f, uint8(channels) & 0x7f}
+}
+
+func encodeMTSChannelsXML(channels uint16) string {
+	list := make([]string, 0, 16)
+	for i := 0; i < 16; i++ {
+		if channels&(1<<uint(i)) != 0 {
+			list = append(list, strconv.Itoa(i+1))
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+func parseMTSChannelsXML(str string) (uint16, error) {
+	channels := uint16(0)
+	for _, field := range strings.Fields(str) {
+		channel, err := strconv.ParseUint(field, 10, 8)
+		if err != nil || channel < 1 || channel > 16 {
+			return 0, fmt.Errorf("unrecognized channel %q", field)
+		}
+		channels |= 1 << uint(channel-1)
+	}
+	return channels, nil
+}
+
+func decodeMTSFromXML(el *etree.Element) (SysEx, error) {
+	attr := func(name string, bitSize int) (uint64, error) {
+		value, err := strconv.ParseUint(el.SelectAttrValue(name, ""), 0, bitSize)
+		if err != nil {
+			return 0, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+		}
+		return value, nil
+	}
+	invalid := func(name string) error {
+		return newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+	}
+	deviceID, err := attr("device", 7)
+	if err != nil {
+		return nil, err
+	}
+	realtime := false
+	switch el.SelectAttrValue("realtime", "no") {
+	case "yes":
+		realtime = true
+	case "no":
+	default:
+		return nil, invalid("realtime")
+	}
+	switch el.Tag {
+	case "MTSBulkDump":
+		program, err := attr("program", 7)
+		if err != nil {
+			return nil, err
+		}
+		name, err := parseTextDump(el.SelectAttrValue("name", ""))
+		if err != nil || len(name) > 16 {
+			return nil, invalid("name")
+		}
+		fields := strings.Fields(el.SelectAttrValue("tunings", ""))
+		if len(fields) != 128 {
+			return nil, invalid("tunings")
+		}
+		sysex := &SysExMTSBulkDump{
+			DeviceID: uint8(deviceID),
+			Program:  uint8(program),
+			Name:     name,
+		}
+		for i, field := range fields {
+			sysex.Tunings[i], err = ParseMTSNoteTuning(field)
+			if err != nil {
+				return nil, invalid("tunings")
+			}
+		}
+		return sysex, nil
+	case "MTSNoteChange":
+		program, err := attr("program", 7)
+		if err != nil {
+			return nil, err
+		}
+		sysex := &SysExMTSNoteChange{
+			DeviceID: uint8(deviceID),
+			Realtime: realtime,
+			Program:  uint8(program),
+			Changes:  make([]MTSKeyTuning, 0),
+		}
+		if el.SelectAttr("bank") != nil {
+			bank, err := attr("bank", 7)
+			if err != nil {
+				return nil, err
+			}
+			sysex.Banked = true
+			sysex.Bank = uint8(bank)
+		}
+		for _, field := range strings.Fields(el.SelectAttrValue("changes", "")) {
+			pair := strings.SplitN(field, "=", 2)
+			if len(pair) != 2 {
+				return nil, invalid("changes")
+			}
+			key, err := ParseKey(pair[0])
+			if err != nil {
+				return nil, invalid("changes")
+			}
+			tuning, err := ParseMTSNoteTuning(pair[1])
+			if err != nil {
+				return nil, invalid("changes")
+			}
+			sysex.Changes = append(sysex.Changes, MTSKeyTuning{Key: key, Tuning: tuning})
+		}
+		return sysex, nil
+	case "MTSScaleOctave1Byte", "MTSScaleOctave2Byte":
+		channels, err := parseMTSChannelsXML(el.SelectAttrValue("channels", ""))
+		if err != nil {
+			return nil, invalid("channels")
+		}
+		fields := strings.Fields(el.SelectAttrValue("offsets", ""))
+		if len(fields) != 12 {
+			return nil, invalid("offsets")
+		}
+		if el.Tag == "MTSScaleOctave1Byte" {
+			sysex := &SysExMTSScaleOctave1Byte{
+				DeviceID: uint8(deviceID),
+				Realtime: realtime,
+				Channels: channels,
+			}
+			for i, field := range fields {
+				offset, err := strconv.ParseInt(field, 10, 8)
+				if err != nil || offset < -64 || offset > 63 {
+					return nil, invalid("offsets")
+				}
+				sysex.Offsets[i] = int8(offset)
+			}
+			return sysex, nil
+		}
+		sysex := &SysExMTSScaleOctave2Byte{
+			DeviceID: uint8(deviceID),
+			Realtime: realtime,
+			Channels: channels,
+		}
+		for i, field := range fields {
+			cents, err := strconv.ParseFloat(field, 64)
+			offset := math.Floor(cents*8192/100 + 0.5)
+			if err != nil || offset < -0x2000 || offset >= 0x2000 {
+				return nil, invalid("offsets")
+			}
+			sysex.Offsets[i] = int16(offset)
+		}
+		return sysex, nil
+	}
+	return nil, nil
+}
+
+func encodeMTSRealtimeXMLAttr(el *etree.Element, realtime bool) {
+	if realtime {
+		el.CreateAttr("realtime", "yes")
+	}
+}
+
+func universalSubID(realtime bool) uint8 {
+	if realtime {
+		return 0x7f
+	}
+	return 0x7e
+}
+
+func (sysex *SysExMTSBulkDump) SysExData() []byte {
+	data := make([]byte, 0, 407)
+	data = append(data, 0x7e, sysex.DeviceID&0x7f, 0x08, 0x01, sysex.Program&0x7f)
+	data = append(data, mtsName(sysex.Name)...)
+	for _, tuning := range sysex.Tunings {
+		data = append(data, tuning.bytes()...)
+	}
+	data = append(data, mtsChecksum(data), 0xf7)
+	return data
+}
+
+// Returns the name as a 16 byte field padded with spaces, non-ASCII
+// characters are replaced with '?'
+func mtsName(name string) []byte {
+	field := []byte("                ")
+	i := 0
+	for _, r := range name {
+		if i == len(field) {
+			break
+		}
+		if r >= 0x80 {
+			r = '?'
+		}
+		field[i] = byte(r)
+		i++
+	}
+	return field
+}
+
+func (sysex *SysExMTSBulkDump) sysexTag() string {
+	return "MTSBulkDump"
+}
+
+func (sysex *SysExMTSBulkDump) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("program", fmt.Sprintf("%d", sysex.Program))
+	el.CreateAttr("name", dumpText(sysex.Name))
+	tunings := make([]string, len(sysex.Tunings))
+	for i, tuning := range sysex.Tunings {
+		tunings[i] = tuning.String()
+	}
+	el.CreateAttr("tunings", strings.Join(tunings, " "))
+}
+
+func (sysex *SysExMTSNoteChange) SysExData() []byte {
+	data := make([]byte, 0, 8+len(sysex.Changes)*4)
+	data = append(data, universalSubID(sysex.Realtime), sysex.DeviceID&0x7f, 0x08)
+	if sysex.Banked {
+		data = append(data, 0x07, sysex.Bank&0x7f)
+	} else {
+		data = append(data, 0x02)
+	}
+	data = append(data, sysex.Program&0x7f, uint8(len(sysex.Changes))&0x7f)
+	for _, change := range sysex.Changes {
+		data = append(data, uint8(change.Key)&0x7f)
+		data = append(data, change.Tuning.bytes()...)
+	}
+	data = append(data, 0xf7)
+	return data
+}
+
+func (sysex *SysExMTSNoteChange) sysexTag() string {
+	return "MTSNoteChange"
+}
+
+func (sysex *SysExMTSNoteChange) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	encodeMTSRealtimeXMLAttr(el, sysex.Realtime)
+	if sysex.Banked {
+		el.CreateAttr("bank", fmt.Sprintf("%d", sysex.Bank))
+	}
+	el.CreateAttr("program", fmt.Sprintf("%d", sysex.Program))
+	changes := make([]string, len(sysex.Changes))
+	for i, change := range sysex.Changes {
+		changes[i] = fmt.Sprintf("%s=%s", change.Key, change.Tuning)
+	}
+	el.CreateAttr("changes", strings.Join(changes, " "))
+}
+
+func (sysex *SysExMTSScaleOctave1Byte) SysExData() []byte {
+	data := make([]byte, 0, 20)
+	data = append(data, universalSubID(sysex.Realtime), sysex.DeviceID&0x7f, 0x08, 0x08)
+	data = append(data, encodeMTSChannels(sysex.Channels)...)
+	for _, offset := range sysex.Offsets {
+		data = append(data, uint8(offset+0x40)&0x7f)
+	}
+	data = append(data, 0xf7)
+	return data
+}
+
+func (sysex *SysExMTSScaleOctave1Byte) sysexTag() string {
+	return "MTSScaleOctave1Byte"
+}
+
+func (sysex *SysExMTSScaleOctave1Byte) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	encodeMTSRealtimeXMLAttr(el, sysex.Realtime)
+	el.CreateAttr("channels", encodeMTSChannelsXML(sysex.Channels))
+	offsets := make([]string, len(sysex.Offsets))
+	for i, offset := range sysex.Offsets {
+		offsets[i] = strconv.Itoa(int(offset))
+	}
+	el.CreateAttr("offsets", strings.Join(offsets, " "))
+}
+
+func (sysex *SysExMTSScaleOctave2Byte) SysExData() []byte {
+	data := make([]byte, 0, 32)
+	data = append(data, universalSubID(sysex.Realtime), sysex.DeviceID&0x7f, 0x08, 0x09)
+	data = append(data, encodeMTSChannels(sysex.Channels)...)
+	for _, offset := range sysex.Offsets {
+		data = append(data, uint8((offset+0x2000)>>7)&0x7f, uint8(offset+0x2000)&0x7f)
+	}
+	data = append(data, 0xf7)
+	return data
+}
+
+func (sysex *SysExMTSScaleOctave2Byte) sysexTag() string {
+	return "MTSScaleOctave2Byte"
+}
+
+// Offsets are written in cents
+func (sysex *SysExMTSScaleOctave2Byte) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	encodeMTSRealtimeXMLAttr(el, sysex.Realtime)
+	el.CreateAttr("channels", encodeMTSChannelsXML(sysex.Channels))
+	offsets := make([]string, len(sysex.Offsets))
+	for i, offset := range sysex.Offsets {
+		offsets[i] = strconv.FormatFloat(float64(offset)*100/8192, 'f', -1, 64)
+	}
+	el.CreateAttr("offsets", strings.Join(offsets, " "))
+}
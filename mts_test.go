@@ -0,0 +1,94 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMTSBulkDumpRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantName string
+	}{
+		{"Pythagorean", "Pythagorean"},
+		{"Exactly16Letters", "Exactly16Letters"},
+		{"Ünïcode tuning name", "?n?code tuning n"},
+	}
+	for _, test := range tests {
+		sysex := &SysExMTSBulkDump{
+			DeviceID: 0x7f,
+			Program:  3,
+			Name:     test.name,
+		}
+		for i := range sysex.Tunings {
+			sysex.Tunings[i] = NewMTSNoteTuning(float64(i)*100 + 12.5)
+		}
+		sysex.Tunings[0] = MTSNoChange
+		data := sysex.SysExData()
+		if len(data) != 407 {
+			t.Errorf("%q: got %d bytes, want 407", test.name, len(data))
+			continue
+		}
+		for i, b := range data[:len(data)-1] {
+			if b >= 0x80 {
+				t.Errorf("%q: byte %d is %#02x", test.name, i, b)
+			}
+		}
+		decoded, err := DecodeSysEx(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.name, err)
+			continue
+		}
+		want := *sysex
+		want.Name = test.wantName
+		if !reflect.DeepEqual(decoded, &want) {
+			t.Errorf("%q: decoded %+v, want %+v", test.name, decoded, &want)
+		}
+	}
+}
+
+func TestMTSNoteChangeRoundTrip(t *testing.T) {
+	sysex := &SysExMTSNoteChange{
+		DeviceID: 0x10,
+		Realtime: true,
+		Banked:   true,
+		Bank:     2,
+		Program:  5,
+		Changes: []MTSKeyTuning{
+			{Key: 60, Tuning: NewMTSNoteTuningFromFrequency(261.6255653005986)},
+			{Key: 69, Tuning: NewMTSNoteTuning(6900 - 31.25)},
+		},
+	}
+	data := sysex.SysExData()
+	decoded, err := DecodeSysEx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, sysex) {
+		t.Errorf("% x decoded to %+v, want %+v", data, decoded, sysex)
+	}
+}
@@ -27,6 +27,7 @@ package midimark
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type Key uint8
@@ -117,3 +118,27 @@ func ParseKeySignature(str string) (KeySignature, error) {
 	}
 	return 0, fmt.Errorf("unrecognized key signature %q", str)
 }
+
+func (pos MusicalPosition) String() string {
+	return fmt.Sprintf("%d:%d:%d", pos.Bar, pos.Beat, pos.Tick)
+}
+
+func ParseMusicalPosition(str string) (MusicalPosition, error) {
+	fields := strings.Split(str, ":")
+	if len(fields) != 3 {
+		return MusicalPosition{}, fmt.Errorf("unrecognized musical position %q", str)
+	}
+	var values [3]int64
+	for i, field := range fields {
+		value, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return MusicalPosition{}, fmt.Errorf("unrecognized musical position %q", str)
+		}
+		values[i] = value
+	}
+	return MusicalPosition{
+		Bar:  values[0],
+		Beat: values[1],
+		Tick: values[2],
+	}, nil
+}
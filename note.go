@@ -0,0 +1,176 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+	"time"
+)
+
+type Note struct {
+	Track       int
+	Channel     uint8
+	Key         Key
+	Velocity    uint8
+	OffVelocity uint8
+	StartTick   int64
+	Duration    int64
+	StartTime   time.Duration
+	EndTime     time.Duration
+	NoteOn      *EventNoteOn
+	NoteOff     *EventNoteOff
+}
+
+// Collects every note of the sequence in playback order.
+// A Note On event without a matching Note Off event is reported as a warning,
+// and the note lasts until the end of its track.
+func (seq *Sequence) Notes(warningCallback WarningCallback) []*Note {
+	seq.ConvertDeltaToAbsTick()
+	seq.CalculateNotePair()
+	endTick := make([]int64, len(seq.Tracks))
+	for i, mtrk := range seq.Tracks {
+		if len(mtrk.Events) != 0 {
+			endTick[i] = mtrk.Events[len(mtrk.Events)-1].Common().AbsTick
+		}
+	}
+	notes := make([]*Note, 0)
+	it := seq.EventIterator()
+	for {
+		timed, ok := it.Next()
+		if !ok {
+			break
+		}
+		noteOn, ok := timed.Event.(*EventNoteOn)
+		if !ok {
+			continue
+		}
+		note := &Note{
+			Track:       timed.Track,
+			Channel:     noteOn.Channel,
+			Key:         noteOn.Key,
+			Velocity:    noteOn.Velocity,
+			OffVelocity: 64,
+			StartTick:   timed.AbsTick,
+			StartTime:   timed.Time,
+			NoteOn:      noteOn,
+			NoteOff:     noteOn.RelatedNoteOff,
+		}
+		end := endTick[timed.Track]
+		if noteOff := noteOn.RelatedNoteOff; noteOff != nil {
+			note.OffVelocity = noteOff.Velocity
+			end = noteOff.AbsTick
+		} else {
+			warningCallback(newEditError(noteOn, fmt.Errorf("note %s at tick %d has no matching Note Off event", noteOn.Key, timed.AbsTick)))
+		}
+		if end < note.StartTick {
+			end = note.StartTick
+		}
+		note.Duration = end - note.StartTick
+		note.EndTime = seq.Tracks[timed.Track].ConvertAbsTickToDuration(end)
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// Replaces every note of the sequence with the given notes.
+// Existing Note On and Note Off events referenced by the notes are updated and
+// moved as needed, new events are created for notes without them, and all
+// other paired notes are removed. Note Off events without a matching Note On
+// event are left in place.
+func (seq *Sequence) SetNotes(notes []*Note) error {
+	for _, note := range notes {
+		if note.Track < 0 || note.Track >= len(seq.Tracks) {
+			return newEditError(note, fmt.Errorf("invalid track index %d", note.Track))
+		}
+		if note.Duration < 0 {
+			return newEditError(note, fmt.Errorf("invalid note duration %d", note.Duration))
+		}
+	}
+
+	seq.ConvertDeltaToAbsTick()
+	seq.CalculateNotePair()
+	reused := make(map[*EventNoteOff]bool)
+	for _, note := range notes {
+		if note.NoteOff != nil {
+			reused[note.NoteOff] = true
+		}
+	}
+	for _, mtrk := range seq.Tracks {
+		events := mtrk.Events[:0]
+		for _, event := range mtrk.Events {
+			switch ev := event.(type) {
+			case *EventNoteOn:
+				continue
+			case *EventNoteOff:
+				if ev.RelatedNoteOn != nil || reused[ev] {
+					continue
+				}
+			}
+			events = append(events, event)
+		}
+		mtrk.Events = events
+	}
+
+	for _, note := range notes {
+		if note.NoteOn == nil {
+			note.NoteOn = &EventNoteOn{}
+		}
+		if note.NoteOff == nil {
+			note.NoteOff = &EventNoteOff{}
+		}
+		note.NoteOn.AbsTick = note.StartTick
+		note.NoteOn.Channel = note.Channel
+		note.NoteOn.Key = note.Key
+		note.NoteOn.Velocity = note.Velocity
+		note.NoteOff.AbsTick = note.StartTick + note.Duration
+		note.NoteOff.Channel = note.Channel
+		note.NoteOff.Key = note.Key
+		note.NoteOff.Velocity = note.OffVelocity
+	}
+	// Sorting is stable, so at the same tick a previous note ends before the next one starts
+	for _, note := range notes {
+		if note.Duration != 0 {
+			seq.Tracks[note.Track].Events = append(seq.Tracks[note.Track].Events, note.NoteOff)
+		}
+	}
+	for _, note := range notes {
+		seq.Tracks[note.Track].Events = append(seq.Tracks[note.Track].Events, note.NoteOn)
+	}
+	for _, note := range notes {
+		if note.Duration == 0 {
+			seq.Tracks[note.Track].Events = append(seq.Tracks[note.Track].Events, note.NoteOff)
+		}
+	}
+
+	for _, mtrk := range seq.Tracks {
+		sortEvents(mtrk)
+	}
+	err := seq.ConvertAbsToDeltaTick()
+	if err != nil {
+		return err
+	}
+	seq.CalculateNotePair()
+	return nil
+}
@@ -0,0 +1,476 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a Player, it can be replaced for testing
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Player writes the realtime encoding of each event of a sequence to a writer
+// at the right moment.
+// Play blocks until the end of the sequence or until Stop is called, other
+// methods can be called from other goroutines meanwhile.
+type Player struct {
+	WarningCallback WarningCallback
+	w               io.Writer
+	clock           Clock
+	events          []TimedEvent
+	mu              sync.Mutex
+	// Held while writing, taken before mu is released so the output keeps its order
+	writeMu         sync.Mutex
+	pending         []byte
+	wakeup          chan struct{}
+	index           int
+	position        time.Duration
+	origin          time.Time
+	playing         bool
+	stopped         bool
+	loopStart       time.Duration
+	loopEnd         time.Duration
+	sounding        [16][128]int
+	clockMaster     bool
+	clockIndex      int64
+	tempoTable      *TempoTable
+	ticksPerQuarter int64
+}
+
+func NewPlayer(seq *Sequence, w io.Writer, clock Clock, warningCallback WarningCallback) *Player {
+	p := &Player{
+		WarningCallback: warningCallback,
+		w:               w,
+		clock:           clock,
+		events:          make([]TimedEvent, 0),
+		wakeup:          make(chan struct{}, 1),
+		tempoTable: &TempoTable{
+			Framerate: seq.Header.Framerate,
+			Division:  seq.Header.Division,
+		},
+		ticksPerQuarter: ticksPerQuarter(seq.Header.Framerate, seq.Header.Division),
+	}
+	// Format 2 tracks are independent patterns, play them one after another
+	offset, end := time.Duration(0), time.Duration(0)
+	lastTrack := 0
+	it := seq.EventIterator()
+	for {
+		timed, ok := it.Next()
+		if !ok {
+			break
+		}
+		if seq.Header.Format == 2 && timed.Track != lastTrack {
+			offset = end
+			lastTrack = timed.Track
+		}
+		timed.Time += offset
+		if timed.Time > end {
+			end = timed.Time
+		}
+		p.events = append(p.events, timed)
+	}
+	if len(seq.Tracks) != 0 && seq.Tracks[0].TempoTable != nil {
+		p.tempoTable = seq.Tracks[0].TempoTable
+	}
+	return p
+}
+
+// Plays from the current position until the end of the sequence, or until Stop is called
+func (p *Player) Play() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = false
+	p.playing = true
+	p.origin = p.clock.Now().Add(-p.position)
+	if p.clockMaster {
+		if p.clockIndex == 0 {
+			p.write(&EventStart{})
+		} else {
+			// Resume from a sixteenth note boundary, since Song Position Pointer can not express anything finer
+			p.clockIndex -= p.clockIndex % 6
+			p.seek(p.clockTime(p.clockIndex))
+			p.writeSongPosition()
+			p.write(&EventContinue{})
+		}
+		err := p.flush()
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		if p.stopped {
+			return nil
+		}
+		if !p.playing {
+			p.mu.Unlock()
+			<-p.wakeup
+			p.mu.Lock()
+			continue
+		}
+		position := p.clock.Now().Sub(p.origin)
+		looping := p.loopEnd > p.loopStart
+		if looping && position >= p.loopEnd {
+			p.wrap(p.loopStart + (position-p.loopEnd)%(p.loopEnd-p.loopStart))
+			err := p.flush()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		var wait time.Duration
+		if p.index < len(p.events) {
+			wait = p.events[p.index].Time - position
+		} else if looping {
+			wait = p.loopEnd - position
+		} else {
+			p.playing = false
+			p.stopped = true
+			p.position = position
+			p.allNotesOff()
+			if p.clockMaster {
+				p.write(&EventStop{})
+			}
+			return p.flush()
+		}
+		// Timing clocks go out before any event scheduled at the same moment
+		sendClock := false
+		if p.clockMaster {
+			if clockWait := p.clockTime(p.clockIndex) - position; clockWait <= wait {
+				wait = clockWait
+				sendClock = true
+			}
+		}
+		if looping && p.loopEnd-position < wait {
+			wait = p.loopEnd - position
+			sendClock = false
+		}
+		if wait > 0 {
+			p.mu.Unlock()
+			select {
+			case <-p.clock.After(wait):
+			case <-p.wakeup:
+			}
+			p.mu.Lock()
+			continue
+		}
+		if sendClock {
+			p.write(&EventTimingClock{})
+			p.clockIndex++
+		} else {
+			p.write(p.events[p.index].Event)
+			p.index++
+		}
+		err := p.flush()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Player) Pause() error {
+	p.mu.Lock()
+	if !p.playing {
+		p.mu.Unlock()
+		return nil
+	}
+	p.position = p.clock.Now().Sub(p.origin)
+	p.playing = false
+	p.notify()
+	p.allNotesOff()
+	if p.clockMaster {
+		p.write(&EventStop{})
+	}
+	return p.unlockAndFlush()
+}
+
+func (p *Player) Resume() error {
+	p.mu.Lock()
+	if p.playing || p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.origin = p.clock.Now().Add(-p.position)
+	p.playing = true
+	p.notify()
+	if p.clockMaster {
+		p.write(&EventContinue{})
+	}
+	return p.unlockAndFlush()
+}
+
+func (p *Player) Stop() error {
+	p.mu.Lock()
+	wasPlaying := p.playing
+	if p.playing {
+		p.position = p.clock.Now().Sub(p.origin)
+	}
+	p.playing = false
+	p.stopped = true
+	p.notify()
+	p.allNotesOff()
+	if wasPlaying && p.clockMaster {
+		p.write(&EventStop{})
+	}
+	return p.unlockAndFlush()
+}
+
+// Moves the playback position, silencing all sounding notes and restoring
+// the program, controllers and pitch bend of each channel at the new position.
+// In clock master mode the position is moved back to a sixteenth note
+// boundary, and a Song Position Pointer is sent.
+func (p *Player) Seek(position time.Duration) error {
+	p.mu.Lock()
+	p.relocate(position)
+	p.notify()
+	return p.unlockAndFlush()
+}
+
+// Makes the player drive external devices with 24 Timing Clock messages per
+// quarter note, along with Start, Stop, Continue and Song Position Pointer
+// messages on transport changes.
+// Sequences with SMPTE based timing are clocked as if one second is a quarter
+// note.
+func (p *Player) SetClockMaster(enabled bool) error {
+	p.mu.Lock()
+	if enabled && !p.clockMaster && p.ticksPerQuarter != 0 {
+		position := p.position
+		if p.playing {
+			position = p.clock.Now().Sub(p.origin)
+		}
+		p.clockIndex = p.clockAt(position)
+		if p.playing {
+			// Join at the next sixteenth note boundary, since Song Position Pointer can not express anything finer
+			p.clockIndex += (6 - p.clockIndex%6) % 6
+			p.writeSongPosition()
+			p.write(&EventContinue{})
+		}
+	}
+	p.clockMaster = enabled && p.ticksPerQuarter != 0
+	p.notify()
+	return p.unlockAndFlush()
+}
+
+// Repeats playback between start and end, end not included.
+// Passing end not after start disables looping.
+func (p *Player) SetLoop(start, end time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loopStart = start
+	p.loopEnd = end
+	p.notify()
+}
+
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.playing {
+		return p.clock.Now().Sub(p.origin)
+	}
+	return p.position
+}
+
+func (p *Player) relocate(position time.Duration) {
+	p.allNotesOff()
+	if !p.clockMaster {
+		p.seek(position)
+		p.chase()
+		return
+	}
+	if p.playing {
+		p.write(&EventStop{})
+	}
+	p.seek(position)
+	// Song Position Pointer counts in sixteenth notes, which are 6 clocks each
+	p.clockIndex -= p.clockIndex % 6
+	p.seek(p.clockTime(p.clockIndex))
+	p.writeSongPosition()
+	p.chase()
+	if p.playing {
+		p.write(&EventContinue{})
+	}
+}
+
+// Jumps back to the loop start. Only the sounding notes are released, the
+// channel states at the loop start are left to the events of the loop.
+func (p *Player) wrap(position time.Duration) {
+	p.releaseNotes()
+	p.seek(position)
+}
+
+// Sends the channel states built from every event before the current position
+func (p *Player) chase() {
+	var states [16]*ChannelState
+	for i := range states {
+		states[i] = NewChannelState(uint8(i + 1))
+	}
+	for _, timed := range p.events[:p.index] {
+		if channel := timed.Event.Common().Channel; channel-1 < 16 {
+			states[channel-1].Update(timed.Event)
+		}
+	}
+	for _, state := range states {
+		for _, event := range state.Events() {
+			p.write(event)
+		}
+	}
+}
+
+func (p *Player) seek(position time.Duration) {
+	if position < 0 {
+		position = 0
+	}
+	p.index = sort.Search(len(p.events), func(i int) bool {
+		return p.events[i].Time >= position
+	})
+	p.position = position
+	p.origin = p.clock.Now().Add(-position)
+	p.clockIndex = p.clockAt(position)
+}
+
+// Returns the index of the first Timing Clock message at or after a position
+func (p *Player) clockAt(position time.Duration) int64 {
+	if p.ticksPerQuarter == 0 {
+		return 0
+	}
+	index := p.tempoTable.ConvertDurationToAbsTick(position) * 24 / p.ticksPerQuarter
+	for index > 0 && p.clockTime(index-1) >= position {
+		index--
+	}
+	for p.clockTime(index) < position {
+		index++
+	}
+	return index
+}
+
+// Returns the time of a Timing Clock message, which may fall between two ticks
+func (p *Player) clockTime(index int64) time.Duration {
+	tick := index * p.ticksPerQuarter / 24
+	remainder := index * p.ticksPerQuarter % 24
+	start := p.tempoTable.ConvertAbsTickToDuration(tick)
+	if remainder == 0 {
+		return start
+	}
+	end := p.tempoTable.ConvertAbsTickToDuration(tick + 1)
+	return start + (end-start)*time.Duration(remainder)/24
+}
+
+func (p *Player) writeSongPosition() {
+	songPosition := p.clockIndex / 6
+	if songPosition > 0x3fff {
+		songPosition = 0x3fff
+	}
+	p.write(&EventSongPositionPointer{
+		SongPosition: uint16(songPosition),
+	})
+}
+
+func (p *Player) notify() {
+	select {
+	case p.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Queues the realtime encoding of an event, to be written by flush
+func (p *Player) write(event Event) {
+	data, err := event.EncodeRealtime()
+	if err != nil {
+		p.WarningCallback(err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	switch ev := event.(type) {
+	case *EventNoteOn:
+		if ev.Channel-1 < 16 && ev.Key < 0x80 {
+			p.sounding[ev.Channel-1][ev.Key]++
+		}
+	case *EventNoteOff:
+		if ev.Channel-1 < 16 && ev.Key < 0x80 && p.sounding[ev.Channel-1][ev.Key] > 0 {
+			p.sounding[ev.Channel-1][ev.Key]--
+		}
+	}
+	p.pending = append(p.pending, data...)
+}
+
+// Writes the queued data with mu released, and locks mu again
+func (p *Player) flush() error {
+	err := p.unlockAndFlush()
+	p.mu.Lock()
+	return err
+}
+
+// Writes the queued data after unlocking mu
+func (p *Player) unlockAndFlush() error {
+	data := p.pending
+	p.pending = nil
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.mu.Unlock()
+	if len(data) == 0 {
+		return nil
+	}
+	_, err := p.w.Write(data)
+	return err
+}
+
+// Releases every note that is still sounding
+func (p *Player) releaseNotes() {
+	for channel := range p.sounding {
+		for key, count := range p.sounding[channel] {
+			for ; count > 0; count-- {
+				p.pending = append(p.pending, 0x80|uint8(channel), uint8(key), 64)
+			}
+			p.sounding[channel][key] = 0
+		}
+	}
+}
+
+// Releases every note that is still sounding, then sends All Notes Off to every channel
+func (p *Player) allNotesOff() {
+	p.releaseNotes()
+	for channel := 0; channel < 16; channel++ {
+		p.pending = append(p.pending, 0xb0|uint8(channel), 123, 0)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// A clock that jumps straight to each deadline, running the hooks due on the way
+type testClock struct {
+	start time.Time
+	now   time.Time
+	hooks []testClockHook
+}
+
+type testClockHook struct {
+	at time.Duration
+	fn func()
+}
+
+func newTestClock(hooks ...testClockHook) *testClock {
+	start := time.Unix(0, 0)
+	return &testClock{start: start, now: start, hooks: hooks}
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	deadline := c.now.Add(d)
+	if len(c.hooks) != 0 && !c.start.Add(c.hooks[0].at).After(deadline) {
+		hook := c.hooks[0]
+		c.hooks = c.hooks[1:]
+		c.now = c.start.Add(hook.at)
+		hook.fn()
+	} else {
+		c.now = deadline
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+type testWrite struct {
+	at   time.Duration
+	data []byte
+}
+
+type testWriter struct {
+	clock  *testClock
+	writes []testWrite
+}
+
+func (w *testWriter) Write(data []byte) (int, error) {
+	w.writes = append(w.writes, testWrite{w.clock.now.Sub(w.clock.start), append([]byte(nil), data...)})
+	return len(data), nil
+}
+
+func (w *testWriter) String() string {
+	s := ""
+	for _, write := range w.writes {
+		s += fmt.Sprintf("\n%v: % x", write.at, write.data)
+	}
+	return s
+}
+
+var testAllNotesOff = []byte{
+	0xb0, 0x7b, 0x00, 0xb1, 0x7b, 0x00, 0xb2, 0x7b, 0x00, 0xb3, 0x7b, 0x00,
+	0xb4, 0x7b, 0x00, 0xb5, 0x7b, 0x00, 0xb6, 0x7b, 0x00, 0xb7, 0x7b, 0x00,
+	0xb8, 0x7b, 0x00, 0xb9, 0x7b, 0x00, 0xba, 0x7b, 0x00, 0xbb, 0x7b, 0x00,
+	0xbc, 0x7b, 0x00, 0xbd, 0x7b, 0x00, 0xbe, 0x7b, 0x00, 0xbf, 0x7b, 0x00,
+}
+
+// Plays a program change and a note of 500ms, the sequence ends at 1s.
+// The Note Off of the sequence goes out as 90 3c 00, the player releases
+// notes with 80 3c 40.
+func testPlay(t *testing.T, setup func(p *Player, clock *testClock), hooks ...func(p *Player, clock *testClock) testClockHook) []testWrite {
+	data := buildTestSMF(buildTestMTrk(
+		0x00, 0xc0, 0x05,
+		0x00, 0x90, 0x3c, 0x64,
+		0x60, 0x80, 0x3c, 0x40,
+		0x60, 0xff, 0x2f, 0x00,
+	), testMTrk)
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newTestClock()
+	w := &testWriter{clock: clock}
+	p := NewPlayer(seq, w, clock, func(err error) {
+		t.Error(err)
+	})
+	for _, hook := range hooks {
+		clock.hooks = append(clock.hooks, hook(p, clock))
+	}
+	if setup != nil {
+		setup(p, clock)
+	}
+	err = p.Play()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.writes
+}
+
+func checkWrites(t *testing.T, name string, got, want []testWrite) {
+	gotWriter, wantWriter := &testWriter{writes: got}, &testWriter{writes: want}
+	if gotWriter.String() != wantWriter.String() {
+		t.Errorf("%s: got%s\nwant%s", name, gotWriter, wantWriter)
+	}
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestPlayerSchedule(t *testing.T) {
+	got := testPlay(t, nil)
+	checkWrites(t, "play", got, []testWrite{
+		{0, []byte{0xc0, 0x05}},
+		{0, []byte{0x90, 0x3c, 0x64}},
+		{500 * time.Millisecond, []byte{0x90, 0x3c, 0x00}},
+		{time.Second, testAllNotesOff},
+	})
+}
+
+func TestPlayerPauseResume(t *testing.T) {
+	got := testPlay(t, nil, func(p *Player, clock *testClock) testClockHook {
+		return testClockHook{200 * time.Millisecond, func() {
+			if err := p.Pause(); err != nil {
+				t.Error(err)
+			}
+			clock.now = clock.start.Add(1200 * time.Millisecond)
+			if err := p.Resume(); err != nil {
+				t.Error(err)
+			}
+		}}
+	})
+	checkWrites(t, "pause", got, []testWrite{
+		{0, []byte{0xc0, 0x05}},
+		{0, []byte{0x90, 0x3c, 0x64}},
+		{200 * time.Millisecond, concatBytes([]byte{0x80, 0x3c, 0x40}, testAllNotesOff)},
+		{1500 * time.Millisecond, []byte{0x90, 0x3c, 0x00}},
+		{2000 * time.Millisecond, testAllNotesOff},
+	})
+}
+
+func TestPlayerSeek(t *testing.T) {
+	got := testPlay(t, nil, func(p *Player, clock *testClock) testClockHook {
+		return testClockHook{200 * time.Millisecond, func() {
+			if err := p.Seek(600 * time.Millisecond); err != nil {
+				t.Error(err)
+			}
+		}}
+	})
+	// The program change is chased, the note is over by then
+	checkWrites(t, "seek", got, []testWrite{
+		{0, []byte{0xc0, 0x05}},
+		{0, []byte{0x90, 0x3c, 0x64}},
+		{200 * time.Millisecond, concatBytes([]byte{0x80, 0x3c, 0x40}, testAllNotesOff, []byte{0xc0, 0x05})},
+		{600 * time.Millisecond, testAllNotesOff},
+	})
+}
+
+func TestPlayerLoop(t *testing.T) {
+	setup := func(p *Player, clock *testClock) {
+		p.SetLoop(0, 400*time.Millisecond)
+	}
+	got := testPlay(t, setup, func(p *Player, clock *testClock) testClockHook {
+		return testClockHook{900 * time.Millisecond, func() {
+			if err := p.Stop(); err != nil {
+				t.Error(err)
+			}
+		}}
+	})
+	// Wrapping around only releases the sounding note, Stop turns off everything
+	checkWrites(t, "loop", got, []testWrite{
+		{0, []byte{0xc0, 0x05}},
+		{0, []byte{0x90, 0x3c, 0x64}},
+		{400 * time.Millisecond, []byte{0x80, 0x3c, 0x40}},
+		{400 * time.Millisecond, []byte{0xc0, 0x05}},
+		{400 * time.Millisecond, []byte{0x90, 0x3c, 0x64}},
+		{800 * time.Millisecond, []byte{0x80, 0x3c, 0x40}},
+		{800 * time.Millisecond, []byte{0xc0, 0x05}},
+		{800 * time.Millisecond, []byte{0x90, 0x3c, 0x64}},
+		{900 * time.Millisecond, concatBytes([]byte{0x80, 0x3c, 0x40}, testAllNotesOff)},
+	})
+}
+
+func TestPlayerClockMasterWhilePlaying(t *testing.T) {
+	got := testPlay(t, nil, func(p *Player, clock *testClock) testClockHook {
+		return testClockHook{300 * time.Millisecond, func() {
+			if err := p.SetClockMaster(true); err != nil {
+				t.Error(err)
+			}
+		}}
+	})
+	// 300ms is past clock 14, the slave joins at sixteenth note 3, which is clock 18 at 375ms
+	if len(got) < 4 || got[2].at != 300*time.Millisecond || !bytes.Equal(got[2].data, []byte{0xf2, 0x03, 0x00, 0xfb}) {
+		t.Fatalf("got%s\nwant Song Position Pointer 3 and Continue at 300ms", &testWriter{writes: got})
+	}
+	if got[3].at != 375*time.Millisecond || !bytes.Equal(got[3].data, []byte{0xf8}) {
+		t.Errorf("first Timing Clock: got % x at %v, want f8 at 375ms", got[3].data, got[3].at)
+	}
+	clocks := 0
+	for _, write := range got {
+		if bytes.Equal(write.data, []byte{0xf8}) {
+			clocks++
+		}
+	}
+	if clocks != 31 {
+		t.Errorf("got %d Timing Clocks, want 31 from clock 18 to 48", clocks)
+	}
+	last := got[len(got)-1]
+	if last.at != time.Second || !bytes.Equal(last.data, concatBytes(testAllNotesOff, []byte{0xfc})) {
+		t.Errorf("got % x at %v, want All Notes Off and Stop at 1s", last.data, last.at)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+)
+
+var ErrInvalidGrid = errors.New("midimark: invalid quantization grid")
+
+type QuantizeOptions struct {
+	// Grid size as a fraction of a whole note, e.g. 16 for sixteenth notes
+	NoteValue int
+	// Use a triplet grid, three grid steps in the time of two
+	Triplet bool
+	// Percentage of the distance to the grid to move, 100 snaps exactly to the
+	// grid, 0 is the same as 100
+	Strength int
+	// Percentage of half a grid step to delay every second grid position, 0 is straight
+	Swing int
+	// Only move notes at most this many ticks away from the grid, 0 means no limit
+	Window int64
+	// Also quantize note ends, otherwise note lengths are kept
+	QuantizeEnds bool
+}
+
+// Moves note starts, and optionally note ends, towards a grid.
+func (seq *Sequence) Quantize(options QuantizeOptions) error {
+	if options.NoteValue <= 0 || options.Strength < 0 || options.Strength > 100 || seq.Header.Division == 0 {
+		return ErrInvalidGrid
+	}
+	strength := int64(options.Strength)
+	if strength == 0 {
+		strength = 100
+	}
+	// Grid positions are num/den ticks apart
+	num := ticksPerQuarter(seq.Header.Framerate, seq.Header.Division) * 4 * 3
+	den := int64(options.NoteValue) * 3
+	if options.Triplet {
+		num = num / 3 * 2
+	}
+	swing := divRound(int64(options.Swing)*num, den*200)
+	quantize := func(absTick int64) int64 {
+		step := divRound(absTick*den, num)
+		target := divRound(step*num, den)
+		if step%2 != 0 {
+			target += swing
+		}
+		distance := target - absTick
+		if options.Window > 0 && (distance > options.Window || distance < -options.Window) {
+			return absTick
+		}
+		return absTick + divRound(distance*strength, 100)
+	}
+
+	seq.ConvertDeltaToAbsTick()
+	seq.CalculateNotePair()
+	restore := seq.saveEventTicks()
+	for _, mtrk := range seq.Tracks {
+		for _, event := range mtrk.Events {
+			noteOn, ok := event.(*EventNoteOn)
+			if !ok {
+				continue
+			}
+			start := quantize(noteOn.AbsTick)
+			if noteOff := noteOn.RelatedNoteOff; noteOff != nil {
+				length := noteOff.AbsTick - noteOn.AbsTick
+				end := start + length
+				if options.QuantizeEnds {
+					end = quantize(noteOff.AbsTick)
+					if end <= start {
+						end = start + length
+					}
+				}
+				noteOff.AbsTick = end
+			}
+			noteOn.AbsTick = start
+		}
+	}
+
+	for _, mtrk := range seq.Tracks {
+		sortEvents(mtrk)
+	}
+	err := seq.ConvertAbsToDeltaTick()
+	if err != nil {
+		restore()
+		return err
+	}
+	seq.CalculateNotePair()
+	return nil
+}
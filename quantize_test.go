@@ -0,0 +1,127 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Quantizes notes of 10 ticks starting at each tick, at 96 ticks per quarter note
+func testQuantize(t *testing.T, options QuantizeOptions, ticks ...int64) []int64 {
+	mtrk := &MTrk{}
+	for i, tick := range ticks {
+		mtrk.Events = append(mtrk.Events,
+			&EventNoteOn{EventCommon: EventCommon{AbsTick: tick, Channel: 1}, Key: Key(60 + i), Velocity: 100},
+			&EventNoteOff{EventCommon: EventCommon{AbsTick: tick + 10, Channel: 1}, Key: Key(60 + i), Velocity: 64},
+		)
+	}
+	mtrk.Events = append(mtrk.Events, &MetaEventEndOfTrack{EventCommon: EventCommon{AbsTick: 1000}})
+	sortEvents(mtrk)
+	if err := mtrk.ConvertAbsToDeltaTick(); err != nil {
+		t.Fatal(err)
+	}
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{mtrk},
+	}
+	if err := seq.Quantize(options); err != nil {
+		t.Fatalf("%+v: %v", options, err)
+	}
+	var starts []int64
+	for _, event := range mtrk.Events {
+		if ev, ok := event.(*EventNoteOn); ok {
+			starts = append(starts, ev.AbsTick)
+			if ev.RelatedNoteOff == nil || ev.RelatedNoteOff.AbsTick != ev.AbsTick+10 {
+				t.Errorf("%+v: note at %d lost its length", options, ev.AbsTick)
+			}
+		}
+	}
+	return starts
+}
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		name    string
+		options QuantizeOptions
+		ticks   []int64
+		want    []int64
+	}{
+		{"straight", QuantizeOptions{NoteValue: 16, Strength: 100}, []int64{5, 20, 30, 50}, []int64{0, 24, 24, 48}},
+		{"default strength", QuantizeOptions{NoteValue: 16}, []int64{5, 20}, []int64{0, 24}},
+		{"half strength", QuantizeOptions{NoteValue: 16, Strength: 50}, []int64{4, 20}, []int64{2, 22}},
+		{"triplet", QuantizeOptions{NoteValue: 8, Triplet: true}, []int64{30, 50, 70}, []int64{32, 64, 64}},
+		{"swing", QuantizeOptions{NoteValue: 16, Swing: 50}, []int64{20, 50, 70}, []int64{30, 48, 78}},
+		{"window", QuantizeOptions{NoteValue: 16, Window: 3}, []int64{2, 10, 22}, []int64{0, 10, 24}},
+	}
+	for _, test := range tests {
+		got := testQuantize(t, test.options, test.ticks...)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v quantized to %v, want %v", test.name, test.ticks, got, test.want)
+		}
+	}
+}
+
+func TestQuantizeInvalidStrength(t *testing.T) {
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{{Events: []Event{&MetaEventEndOfTrack{}}}},
+	}
+	for _, strength := range []int{-1, 101} {
+		err := seq.Quantize(QuantizeOptions{NoteValue: 16, Strength: strength})
+		if err != ErrInvalidGrid {
+			t.Errorf("strength %d: got error %v, want %v", strength, err, ErrInvalidGrid)
+		}
+	}
+}
+
+func TestQuantizeRestoresOnError(t *testing.T) {
+	// The note is moved 9 ticks later, past the longest possible delta
+	events := []Event{
+		&EventProgramChange{EventCommon: EventCommon{Channel: 1}, Program: 1},
+		&EventNoteOn{EventCommon: EventCommon{DeltaTick: MaxVLQ, Channel: 1}, Key: 60, Velocity: 100},
+		&EventNoteOff{EventCommon: EventCommon{DeltaTick: 10, Channel: 1}, Key: 60, Velocity: 64},
+		&MetaEventEndOfTrack{},
+	}
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{{Events: append([]Event(nil), events...)}},
+	}
+	err := seq.Quantize(QuantizeOptions{NoteValue: 16})
+	if err != ErrDeltaToBig {
+		t.Fatalf("got error %v, want %v", err, ErrDeltaToBig)
+	}
+	wantTicks := []int64{0, MaxVLQ, MaxVLQ + 10, MaxVLQ + 10}
+	wantDeltas := []VLQ{0, MaxVLQ, 10, 0}
+	for i, event := range seq.Tracks[0].Events {
+		evCommon := event.Common()
+		if event != events[i] {
+			t.Errorf("event %d reordered", i)
+		}
+		if evCommon.AbsTick != wantTicks[i] || evCommon.DeltaTick != wantDeltas[i] {
+			t.Errorf("event %d: tick %d delta %d not restored", i, evCommon.AbsTick, evCommon.DeltaTick)
+		}
+	}
+}
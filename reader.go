@@ -0,0 +1,139 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+type SMFReader struct {
+	r               io.ReadSeeker
+	warningCallback WarningCallback
+	header          *MThd
+	chunks          []*Chunk
+	finished        bool
+	track           int
+	trackReader     io.ReadSeeker
+	trackPos        int64
+	trackLength     uint32
+	status          uint8
+	channel         uint8
+	absTick         int64
+}
+
+func NewSMFReader(r io.ReadSeeker, warningCallback WarningCallback) (*SMFReader, error) {
+	mthd, err := DecodeMThdFromSMF(r, warningCallback)
+	if err != nil {
+		return nil, err
+	}
+	if mthd.NTrks == 0 {
+		warningCallback(newSMFDecodeError(tell(r)+10, errors.New("MIDI file seems to contain no tracks")))
+	}
+	return &SMFReader{
+		r:               r,
+		warningCallback: warningCallback,
+		header:          mthd,
+		track:           -1,
+	}, nil
+}
+
+func (sr *SMFReader) Header() *MThd {
+	return sr.header
+}
+
+// Returns the chunks of unknown types read so far, including those after the
+// last track once Next has returned io.EOF
+func (sr *SMFReader) Chunks() []*Chunk {
+	return sr.chunks
+}
+
+// Returns io.EOF after the last event of the last track
+func (sr *SMFReader) Next() (track int, event Event, err error) {
+	for {
+		if sr.trackReader == nil {
+			err = sr.nextTrack()
+			if err != nil {
+				return sr.track, nil, err
+			}
+		}
+		event, err = decodeMTrkEvent(sr.trackReader, sr.trackPos, sr.trackLength, &sr.status, &sr.channel, sr.warningCallback)
+		if event != nil {
+			sr.absTick += int64(event.Common().DeltaTick)
+			event.Common().AbsTick = sr.absTick
+		}
+		if err == io.EOF {
+			sr.trackReader = nil
+			err = nil
+			if event == nil {
+				continue
+			}
+		}
+		return sr.track, event, err
+	}
+}
+
+func (sr *SMFReader) nextTrack() error {
+	if sr.header.NTrks != 0 && sr.track+1 >= int(sr.header.NTrks) {
+		if !sr.finished {
+			sr.finished = true
+			sr.readTrailingChunks()
+		}
+		return io.EOF
+	}
+	pos, length, err := decodeMTrkHeader(sr.r, sr.warningCallback, func(chunk *Chunk) {
+		chunk.TrackIndex = sr.track + 1
+		sr.chunks = append(sr.chunks, chunk)
+	})
+	if err != nil {
+		if err == io.EOF && sr.header.NTrks != 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	sr.trackReader, err = newMTrkReadSeeker(sr.r, pos, length, sr.warningCallback)
+	if err != nil {
+		return err
+	}
+	sr.track++
+	sr.trackPos = pos
+	sr.trackLength = length
+	sr.status = 0x80
+	sr.channel = 0
+	sr.absTick = 0
+	return nil
+}
+
+// Collects the chunks after the last track, as DecodeSequenceFromSMF does
+func (sr *SMFReader) readTrailingChunks() {
+	pos := tell(sr.r)
+	trailing, err := ioutil.ReadAll(sr.r)
+	if err != nil {
+		sr.warningCallback(newSMFDecodeError(pos, err))
+	}
+	chunks, _ := decodeTrailingChunks(trailing, pos, sr.track+1)
+	sr.chunks = append(sr.chunks, chunks...)
+}
@@ -0,0 +1,182 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RealtimeParser decodes a live MIDI byte stream pushed through Write.
+// Messages may be split across writes, System Realtime bytes may appear in the
+// middle of other messages, and running status is honored. After unexpected
+// bytes the parser skips ahead to the next status byte.
+// FilePosition of each event is its offset in the whole stream.
+type RealtimeParser struct {
+	Callback        func(event Event)
+	WarningCallback WarningCallback
+	pos             int64
+	msgPos          int64
+	status          uint8
+	msg             []byte
+	sysex           bool
+	skipping        bool
+}
+
+func NewRealtimeParser(callback func(event Event), warningCallback WarningCallback) *RealtimeParser {
+	return &RealtimeParser{
+		Callback:        callback,
+		WarningCallback: warningCallback,
+		msg:             make([]byte, 0, 3),
+	}
+}
+
+func (p *RealtimeParser) Write(data []byte) (n int, err error) {
+	for _, b := range data {
+		p.writeByte(b)
+		p.pos++
+	}
+	return len(data), nil
+}
+
+// Discards any partial message and the running status
+func (p *RealtimeParser) Reset() {
+	p.status = 0
+	p.msg = p.msg[:0]
+	p.sysex = false
+	p.skipping = false
+}
+
+func (p *RealtimeParser) writeByte(b byte) {
+	switch {
+	case b >= 0xf8:
+		// System Realtime messages can appear anywhere, even inside other messages
+		p.emit([]byte{b}, p.pos)
+	case b == 0xf7:
+		if !p.sysex {
+			p.skip(fmt.Errorf("unexpected end of exclusive %#02x", b))
+			return
+		}
+		p.msg = append(p.msg, b)
+		p.emitSysEx()
+	case b >= 0x80:
+		if p.sysex {
+			p.WarningCallback(newSMFDecodeError(p.pos, fmt.Errorf("system exclusive message interrupted by status byte %#02x", b)))
+			p.msg = append(p.msg, 0xf7)
+			p.emitSysEx()
+		} else if len(p.msg) != 0 {
+			p.WarningCallback(newSMFDecodeError(p.msgPos, fmt.Errorf("incomplete MIDI event % x", p.msg)))
+		}
+		p.skipping = false
+		p.msg = append(p.msg[:0], b)
+		p.msgPos = p.pos
+		if b < 0xf0 {
+			p.status = b
+		} else {
+			// System Common messages cancel running status
+			p.status = 0
+		}
+		if b == 0xf0 {
+			p.sysex = true
+			return
+		}
+		p.emitIfComplete()
+	default:
+		if p.sysex {
+			p.msg = append(p.msg, b)
+			return
+		}
+		if len(p.msg) == 0 {
+			if p.status < 0x80 {
+				p.skip(fmt.Errorf("unexpected data byte %#02x without status", b))
+				return
+			}
+			p.msg = append(p.msg, p.status)
+			p.msgPos = p.pos
+		}
+		p.msg = append(p.msg, b)
+		p.emitIfComplete()
+	}
+}
+
+func (p *RealtimeParser) skip(err error) {
+	if !p.skipping {
+		p.WarningCallback(newSMFDecodeError(p.pos, err))
+		p.skipping = true
+	}
+}
+
+func (p *RealtimeParser) emitIfComplete() {
+	length := 0
+	switch p.msg[0] & 0xf0 {
+	case 0x80, 0x90, 0xa0, 0xb0, 0xe0:
+		length = 3
+	case 0xc0, 0xd0:
+		length = 2
+	default:
+		switch p.msg[0] {
+		case 0xf1, 0xf3:
+			length = 2
+		case 0xf2:
+			length = 3
+		default:
+			length = 1
+		}
+	}
+	if len(p.msg) < length {
+		return
+	}
+	p.emit(p.msg, p.msgPos)
+	p.msg = p.msg[:0]
+}
+
+func (p *RealtimeParser) emitSysEx() {
+	data := make([]byte, len(p.msg)-1)
+	copy(data, p.msg[1:])
+	p.Callback(&EventSystemExclusive{
+		EventCommon: EventCommon{
+			FilePosition: p.msgPos,
+		},
+		Data: data,
+	})
+	p.msg = p.msg[:0]
+	p.sysex = false
+}
+
+func (p *RealtimeParser) emit(msg []byte, pos int64) {
+	status := p.status
+	event, err := DecodeEventFromRealtime(bytes.NewReader(msg), &status, func(err error) {
+		if decodeErr, ok := err.(*ErrSMFDecode); ok {
+			err = newSMFDecodeError(pos+decodeErr.Pos, decodeErr.Err)
+		}
+		p.WarningCallback(err)
+	})
+	if err != nil {
+		p.WarningCallback(newSMFDecodeError(pos, err))
+		return
+	}
+	event.Common().FilePosition = pos
+	p.Callback(event)
+}
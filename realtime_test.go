@@ -0,0 +1,96 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRealtimeParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   [][]byte
+		events   []string
+		warnings int
+	}{
+		{
+			"running status",
+			[][]byte{{0x90, 0x3c, 0x64, 0x3e, 0x64, 0x3c, 0x00}},
+			[]string{"0: 90 3c 64", "3: 90 3e 64", "5: 90 3c 00"},
+			0,
+		},
+		{
+			"timing clock inside note on",
+			[][]byte{{0x90, 0x3c, 0xf8, 0x64}},
+			[]string{"2: f8", "0: 90 3c 64"},
+			0,
+		},
+		{
+			"sysex split across writes",
+			[][]byte{{0xf0, 0x7e, 0x7f}, {0xf8, 0x09}, {0x01}, {0xf7, 0xc0, 0x05}},
+			[]string{"3: f8", "0: f0 7e 7f 09 01 f7", "7: c0 05"},
+			0,
+		},
+		{
+			"garbage before status",
+			[][]byte{{0x3c, 0x64, 0x12}, {0xf7, 0x90, 0x3c, 0x64}},
+			[]string{"4: 90 3c 64"},
+			1,
+		},
+		{
+			"incomplete message",
+			[][]byte{{0x90, 0x3c, 0xc0, 0x05, 0x06}},
+			[]string{"2: c0 05", "4: c0 06"},
+			1,
+		},
+	}
+	for _, test := range tests {
+		var events []string
+		warnings := 0
+		p := NewRealtimeParser(func(event Event) {
+			data, err := event.EncodeRealtime()
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			events = append(events, fmt.Sprintf("%d: % x", event.Common().FilePosition, data))
+		}, func(err error) {
+			warnings++
+		})
+		for _, data := range test.writes {
+			n, err := p.Write(data)
+			if n != len(data) || err != nil {
+				t.Errorf("%s: wrote %d of %d bytes, %v", test.name, n, len(data), err)
+			}
+		}
+		if !reflect.DeepEqual(events, test.events) {
+			t.Errorf("%s: got events %q, want %q", test.name, events, test.events)
+		}
+		if warnings != test.warnings {
+			t.Errorf("%s: got %d warnings, want %d", test.name, warnings, test.warnings)
+		}
+	}
+}
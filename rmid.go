@@ -0,0 +1,306 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// RMID is a standard MIDI file wrapped in a RIFF container
+type RMID struct {
+	FilePosition int64
+	Sequence     *Sequence
+	// Tags of the INFO list, in their original order
+	Info []RIFFInfoTag
+	// The embedded "RIFF" "DLS " chunk including its header, or nil
+	DLS []byte
+	// The chunks of the RMID form in their original order. The data chunk,
+	// the INFO list and the DLS chunk are kept as placeholders with nil Data,
+	// their contents come from Sequence, Info and DLS.
+	Chunks []RIFFChunk
+}
+
+// ID is a four character code, such as INAM, IART or ICOP
+type RIFFInfoTag struct {
+	ID   string
+	Text string
+}
+
+type RIFFChunk struct {
+	FilePosition int64
+	ID           [4]byte
+	Data         []byte
+}
+
+// Returns the text of the first INFO tag with an ID
+func (rmid *RMID) InfoTag(id string) (string, bool) {
+	for _, tag := range rmid.Info {
+		if tag.ID == id {
+			return tag.Text, true
+		}
+	}
+	return "", false
+}
+
+// Replaces the text of an INFO tag, or appends one if it does not exist
+func (rmid *RMID) SetInfoTag(id, text string) {
+	for i := range rmid.Info {
+		if rmid.Info[i].ID == id {
+			rmid.Info[i].Text = text
+			return
+		}
+	}
+	rmid.Info = append(rmid.Info, RIFFInfoTag{ID: id, Text: text})
+}
+
+// File positions inside the Sequence are counted from the start of the RIFF file.
+// If the data chunk can not be decoded, the partial Sequence is returned along
+// with the error. A read error other than EOF is returned along with the
+// chunks read so far.
+func DecodeRMIDFromRIFF(r io.ReadSeeker, warningCallback WarningCallback) (rmid *RMID, err error) {
+	pos := tell(r)
+	var buf [12]byte
+	_, err = io.ReadFull(r, buf[:])
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = newSMFDecodeError(pos, errors.New("not a RIFF RMID file"))
+		}
+		return
+	}
+	if !bytes.Equal(buf[:4], []byte{'R', 'I', 'F', 'F'}) || !bytes.Equal(buf[8:12], []byte{'R', 'M', 'I', 'D'}) {
+		return nil, newSMFDecodeError(pos, errors.New("not a RIFF RMID file"))
+	}
+	rmid = &RMID{
+		FilePosition: pos,
+	}
+	remaining := int64(binary.LittleEndian.Uint32(buf[4:8])) - 4
+	for remaining >= 8 {
+		var chunk RIFFChunk
+		chunk, err = decodeRIFFChunk(r, warningCallback)
+		if err != nil {
+			if err == io.EOF {
+				warningCallback(newSMFDecodeError(tell(r), io.ErrUnexpectedEOF))
+				err = nil
+			}
+			break
+		}
+		remaining -= 8 + int64(len(chunk.Data)+len(chunk.Data)%2)
+		switch string(chunk.ID[:]) {
+		case "data":
+			if rmid.Sequence != nil {
+				warningCallback(newSMFDecodeError(chunk.FilePosition, errors.New("duplicate RMID data chunk")))
+				rmid.Chunks = append(rmid.Chunks, chunk)
+				continue
+			}
+			rmid.Sequence, err = DecodeSequenceFromSMF(&offsetReadSeeker{
+				r:      bytes.NewReader(chunk.Data),
+				offset: chunk.FilePosition + 8,
+			}, warningCallback)
+			rmid.Chunks = append(rmid.Chunks, RIFFChunk{FilePosition: chunk.FilePosition, ID: chunk.ID})
+			if err != nil {
+				return rmid, err
+			}
+		case "LIST":
+			if rmid.Info == nil && len(chunk.Data) >= 4 && string(chunk.Data[:4]) == "INFO" {
+				rmid.Info = append([]RIFFInfoTag{}, decodeRIFFInfo(chunk.Data[4:], chunk.FilePosition+12, warningCallback)...)
+				rmid.Chunks = append(rmid.Chunks, RIFFChunk{FilePosition: chunk.FilePosition, ID: chunk.ID})
+			} else {
+				rmid.Chunks = append(rmid.Chunks, chunk)
+			}
+		case "RIFF":
+			if rmid.DLS == nil && len(chunk.Data) >= 4 && string(chunk.Data[:4]) == "DLS " {
+				rmid.DLS = encodeRIFFChunk(chunk.ID, chunk.Data)[:8+len(chunk.Data)]
+				rmid.Chunks = append(rmid.Chunks, RIFFChunk{FilePosition: chunk.FilePosition, ID: chunk.ID})
+			} else {
+				rmid.Chunks = append(rmid.Chunks, chunk)
+			}
+		default:
+			rmid.Chunks = append(rmid.Chunks, chunk)
+		}
+	}
+	if err != nil {
+		return rmid, err
+	}
+	if rmid.Sequence == nil {
+		return nil, newSMFDecodeError(pos, errors.New("RMID file contains no data chunk"))
+	}
+	return
+}
+
+func decodeRIFFChunk(r io.ReadSeeker, warningCallback WarningCallback) (chunk RIFFChunk, err error) {
+	chunk.FilePosition = tell(r)
+	var buf [8]byte
+	_, err = io.ReadFull(r, buf[:])
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return
+	}
+	copy(chunk.ID[:], buf[:4])
+	length := binary.LittleEndian.Uint32(buf[4:8])
+	chunk.Data, err = ioutil.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return
+	}
+	if uint32(len(chunk.Data)) != length {
+		warningCallback(newSMFDecodeError(chunk.FilePosition, fmt.Errorf("truncated RIFF %q chunk", chunk.ID[:])))
+		return
+	}
+	if length%2 != 0 {
+		// Chunks are padded to an even length, the last pad byte may be missing
+		_, err = io.ReadFull(r, buf[:1])
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	return
+}
+
+func decodeRIFFInfo(data []byte, pos int64, warningCallback WarningCallback) []RIFFInfoTag {
+	var tags []RIFFInfoTag
+	for len(data) >= 8 {
+		length := int(binary.LittleEndian.Uint32(data[4:8]))
+		if length > len(data)-8 {
+			warningCallback(newSMFDecodeError(pos, fmt.Errorf("truncated INFO %q tag", data[:4])))
+			length = len(data) - 8
+		}
+		tags = append(tags, RIFFInfoTag{
+			ID:   string(data[:4]),
+			Text: strings.TrimRight(string(data[8:8+length]), "\x00"),
+		})
+		length += length % 2
+		if length > len(data)-8 {
+			break
+		}
+		data = data[8+length:]
+		pos += 8 + int64(length)
+	}
+	return tags
+}
+
+func encodeRIFFChunk(id [4]byte, data []byte) []byte {
+	buf := make([]byte, 8, 8+len(data)+len(data)%2)
+	copy(buf[:4], id[:])
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(len(data)))
+	buf = append(buf, data...)
+	if len(data)%2 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// Chunks are written in the order of Chunks. Without a placeholder, the data
+// chunk is written first, and the INFO list and the DLS chunk last.
+func (rmid *RMID) EncodeRIFF(w io.Writer) error {
+	if rmid.Sequence == nil {
+		return newSMFEncodeError(rmid, errors.New("RMID file contains no sequence"))
+	}
+	var smf bytes.Buffer
+	err := rmid.Sequence.EncodeSMF(&smf)
+	if err != nil {
+		return err
+	}
+	// Each of them is written once, at its placeholder if there is one
+	hasPlaceholder := make(map[string]bool)
+	for _, chunk := range rmid.Chunks {
+		if id := string(chunk.ID[:]); (id == "data" || id == "LIST" || id == "RIFF") && chunk.Data == nil {
+			hasPlaceholder[id] = true
+		}
+	}
+	data := encodeRIFFChunk([4]byte{'d', 'a', 't', 'a'}, smf.Bytes())
+	var info []byte
+	if len(rmid.Info) != 0 || hasPlaceholder["LIST"] {
+		list := []byte{'I', 'N', 'F', 'O'}
+		for _, tag := range rmid.Info {
+			if len(tag.ID) != 4 {
+				return newSMFEncodeError(rmid, fmt.Errorf("invalid INFO tag ID %q", tag.ID))
+			}
+			var id [4]byte
+			copy(id[:], tag.ID)
+			list = append(list, encodeRIFFChunk(id, append([]byte(tag.Text), 0))...)
+		}
+		info = encodeRIFFChunk([4]byte{'L', 'I', 'S', 'T'}, list)
+	}
+	dls := rmid.DLS
+	if len(dls)%2 != 0 {
+		dls = append(dls[:len(dls):len(dls)], 0)
+	}
+	placed := map[string]*[]byte{"data": &data, "LIST": &info, "RIFF": &dls}
+	body := []byte{'R', 'M', 'I', 'D'}
+	if !hasPlaceholder["data"] {
+		body = append(body, data...)
+	}
+	for _, chunk := range rmid.Chunks {
+		if content, ok := placed[string(chunk.ID[:])]; ok && chunk.Data == nil {
+			body = append(body, *content...)
+			*content = nil
+			continue
+		}
+		body = append(body, encodeRIFFChunk(chunk.ID, chunk.Data)...)
+	}
+	if !hasPlaceholder["LIST"] {
+		body = append(body, info...)
+	}
+	if !hasPlaceholder["RIFF"] {
+		body = append(body, dls...)
+	}
+	if int64(len(body)) > 0xffffffff {
+		return newSMFEncodeError(rmid, errors.New("RMID file is too large"))
+	}
+	_, err = w.Write(encodeRIFFChunk([4]byte{'R', 'I', 'F', 'F'}, body))
+	return err
+}
+
+func (seq *Sequence) EncodeRMID(w io.Writer) error {
+	rmid := &RMID{
+		Sequence: seq,
+	}
+	return rmid.EncodeRIFF(w)
+}
+
+// Presents a chunk read into memory at its position in the file
+type offsetReadSeeker struct {
+	r      io.ReadSeeker
+	offset int64
+}
+
+func (s *offsetReadSeeker) Read(p []byte) (n int, err error) {
+	return s.r.Read(p)
+}
+
+func (s *offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset -= s.offset
+	}
+	pos, err := s.r.Seek(offset, whence)
+	return pos + s.offset, err
+}
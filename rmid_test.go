@@ -0,0 +1,106 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func buildTestRIFF(id string, data []byte) []byte {
+	buf := make([]byte, 8, 8+len(data)+1)
+	copy(buf, id)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(data)))
+	buf = append(buf, data...)
+	if len(data)%2 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func TestRMIDChunkOrder(t *testing.T) {
+	smf := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96, 'M', 'T', 'r', 'k', 0, 0, 0, 4, 0x00, 0xff, 0x2f, 0x00}
+	body := []byte("RMID")
+	body = append(body, buildTestRIFF("LIST", append([]byte("INFO"), buildTestRIFF("INAM", []byte("Song\x00"))...))...)
+	body = append(body, buildTestRIFF("JUNK", []byte{1, 2, 3})...)
+	body = append(body, buildTestRIFF("data", smf)...)
+	body = append(body, buildTestRIFF("RIFF", []byte("DLS \x00\x00"))...)
+	file := buildTestRIFF("RIFF", body)
+
+	rmid, err := DecodeRMIDFromRIFF(bytes.NewReader(file), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := rmid.InfoTag("INAM"); name != "Song" || len(rmid.DLS) == 0 {
+		t.Errorf("INFO or DLS not decoded: %+v", rmid)
+	}
+	var buf bytes.Buffer
+	err = rmid.EncodeRIFF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), file) {
+		t.Errorf("round trip changed the file:\n% x\nwant\n% x", buf.Bytes(), file)
+	}
+}
+
+func TestRMIDPartialSequence(t *testing.T) {
+	// The header promises a track that is missing
+	smf := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96}
+	file := buildTestRIFF("RIFF", append([]byte("RMID"), buildTestRIFF("data", smf)...))
+	rmid, err := DecodeRMIDFromRIFF(bytes.NewReader(file), IgnoreWarnings)
+	if err == nil {
+		t.Fatal("missing track was not reported")
+	}
+	if rmid == nil || rmid.Sequence == nil || rmid.Sequence.Header.Division != 96 {
+		t.Errorf("partial sequence was not returned: %+v", rmid)
+	}
+}
+
+func TestRMIDReadError(t *testing.T) {
+	info := buildTestRIFF("LIST", append([]byte("INFO"), buildTestRIFF("INAM", []byte("Song\x00"))...))
+	file := buildTestRIFF("RIFF", append([]byte("RMID"), info...))
+	// The form promises a data chunk, but reading fails where it would start
+	binary.LittleEndian.PutUint32(file[4:], uint32(len(file)-8+34))
+	errRead := errors.New("read error")
+	r := NewStreamReadSeeker(io.MultiReader(bytes.NewReader(file), iotest.ErrReader(errRead)))
+	rmid, err := DecodeRMIDFromRIFF(r, IgnoreWarnings)
+	if err != errRead {
+		t.Errorf("got error %v, want %v", err, errRead)
+	}
+	if rmid == nil || len(rmid.Info) != 1 {
+		t.Errorf("chunks read before the error were not returned: %+v", rmid)
+	}
+
+	// At the end of file, only the missing data chunk is reported
+	_, err = DecodeRMIDFromRIFF(bytes.NewReader(file), IgnoreWarnings)
+	if _, ok := err.(*ErrSMFDecode); !ok {
+		t.Errorf("got error %v, want a missing data chunk", err)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ScalaScale is a scale read from a Scala .scl file.
+// Pitches are in cents above the base note, which is not listed, and the last
+// pitch is the period of the scale, usually an octave.
+type ScalaScale struct {
+	Description string
+	Pitches     []float64
+}
+
+// ScalaKeyboardMapping is read from a Scala .kbm file.
+// Mapping lists the scale degree of each key in a cycle starting at
+// MiddleNote, -1 for unmapped keys. An empty Mapping maps keys linearly.
+// Each cycle of Mapping is OctaveDegree scale degrees above the previous one,
+// 0 meaning one period of the scale.
+type ScalaKeyboardMapping struct {
+	FirstNote          Key
+	LastNote           Key
+	MiddleNote         Key
+	ReferenceNote      Key
+	ReferenceFrequency float64
+	OctaveDegree       int
+	Mapping            []int
+}
+
+// The mapping Scala uses without a .kbm file: keys mapped linearly, scale base on C4, A4 at 440 Hz
+var DefaultScalaKeyboardMapping = ScalaKeyboardMapping{
+	FirstNote:          0,
+	LastNote:           127,
+	MiddleNote:         60,
+	ReferenceNote:      69,
+	ReferenceFrequency: 440,
+}
+
+// Returns the lines that are not comments
+func readScalaLines(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func ParseScalaScale(r io.Reader) (*ScalaScale, error) {
+	lines, err := readScalaLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("midimark: incomplete Scala scale")
+	}
+	count, err := strconv.Atoi(firstField(lines[1]))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("midimark: invalid Scala scale size %q", lines[1])
+	}
+	scale := &ScalaScale{
+		Description: strings.TrimSpace(lines[0]),
+		Pitches:     make([]float64, 0, count),
+	}
+	for _, line := range lines[2:] {
+		if len(scale.Pitches) == count {
+			break
+		}
+		field := firstField(line)
+		if field == "" {
+			continue
+		}
+		var cents float64
+		if strings.Contains(field, ".") {
+			cents, err = strconv.ParseFloat(field, 64)
+		} else {
+			var numerator, denominator float64 = 0, 1
+			parts := strings.SplitN(field, "/", 2)
+			numerator, err = strconv.ParseFloat(parts[0], 64)
+			if err == nil && len(parts) == 2 {
+				denominator, err = strconv.ParseFloat(parts[1], 64)
+			}
+			if err == nil && (numerator <= 0 || denominator <= 0) {
+				err = fmt.Errorf("non-positive ratio")
+			}
+			cents = 1200 * math.Log2(numerator/denominator)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("midimark: invalid Scala pitch %q", line)
+		}
+		scale.Pitches = append(scale.Pitches, cents)
+	}
+	if len(scale.Pitches) != count {
+		return nil, fmt.Errorf("midimark: incomplete Scala scale")
+	}
+	return scale, nil
+}
+
+func ParseScalaKeyboardMapping(r io.Reader) (*ScalaKeyboardMapping, error) {
+	lines, err := readScalaLines(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if field := firstField(line); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("midimark: incomplete Scala keyboard mapping")
+	}
+	var values [7]int
+	for i, field := range fields[:7] {
+		if i == 5 {
+			continue
+		}
+		values[i], err = strconv.Atoi(field)
+		if err != nil || values[i] < 0 || (i >= 1 && i <= 4 && values[i] >= 0x80) {
+			return nil, fmt.Errorf("midimark: invalid Scala keyboard mapping value %q", field)
+		}
+	}
+	frequency, err := strconv.ParseFloat(fields[5], 64)
+	if err != nil || frequency <= 0 {
+		return nil, fmt.Errorf("midimark: invalid Scala reference frequency %q", fields[5])
+	}
+	mapping := &ScalaKeyboardMapping{
+		FirstNote:          Key(values[1]),
+		LastNote:           Key(values[2]),
+		MiddleNote:         Key(values[3]),
+		ReferenceNote:      Key(values[4]),
+		ReferenceFrequency: frequency,
+		OctaveDegree:       values[6],
+		Mapping:            make([]int, values[0]),
+	}
+	for i := range mapping.Mapping {
+		// Missing entries at the end are unmapped
+		mapping.Mapping[i] = -1
+		if 7+i >= len(fields) || fields[7+i] == "x" {
+			continue
+		}
+		mapping.Mapping[i], err = strconv.Atoi(fields[7+i])
+		if err != nil || mapping.Mapping[i] < 0 {
+			return nil, fmt.Errorf("midimark: invalid Scala keyboard mapping entry %q", fields[7+i])
+		}
+	}
+	return mapping, nil
+}
+
+func firstField(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// Builds the tuning of every key from a scale and a keyboard mapping, ready
+// for SysExMTSBulkDump.
+// Passing a nil mapping uses DefaultScalaKeyboardMapping. Unmapped keys, and
+// keys outside the mapped range, are MTSNoChange.
+func NewMTSTuningFromScala(scale *ScalaScale, mapping *ScalaKeyboardMapping) [128]MTSNoteTuning {
+	if mapping == nil {
+		mapping = &DefaultScalaKeyboardMapping
+	}
+	var tunings [128]MTSNoteTuning
+	for i := range tunings {
+		tunings[i] = MTSNoChange
+	}
+	if len(scale.Pitches) == 0 {
+		return tunings
+	}
+	reference, ok := scalaKeyCents(scale, mapping, mapping.ReferenceNote)
+	if !ok {
+		return tunings
+	}
+	base := 6900 + 1200*math.Log2(mapping.ReferenceFrequency/440) - reference
+	for key := int(mapping.FirstNote); key <= int(mapping.LastNote) && key < len(tunings); key++ {
+		if cents, ok := scalaKeyCents(scale, mapping, Key(key)); ok {
+			tunings[key] = NewMTSNoteTuning(base + cents)
+		}
+	}
+	return tunings
+}
+
+// Returns the pitch of a key in cents above the middle note
+func scalaKeyCents(scale *ScalaScale, mapping *ScalaKeyboardMapping, key Key) (float64, bool) {
+	degree := int(key) - int(mapping.MiddleNote)
+	if len(mapping.Mapping) != 0 {
+		size := len(mapping.Mapping)
+		cycles, index := floorDivMod(degree, size)
+		if mapping.Mapping[index] < 0 {
+			return 0, false
+		}
+		octaveDegree := mapping.OctaveDegree
+		if octaveDegree == 0 {
+			octaveDegree = len(scale.Pitches)
+		}
+		degree = cycles*octaveDegree + mapping.Mapping[index]
+	}
+	periods, index := floorDivMod(degree, len(scale.Pitches))
+	cents := float64(periods) * scale.Pitches[len(scale.Pitches)-1]
+	if index != 0 {
+		cents += scale.Pitches[index-1]
+	}
+	return cents, true
+}
+
+func floorDivMod(a, b int) (int, int) {
+	q, r := a/b, a%b
+	if r < 0 {
+		q--
+		r += b
+	}
+	return q, r
+}
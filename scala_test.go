@@ -0,0 +1,114 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testScala = `! test.scl
+!
+Test scale
+ 3
+!
+ 200.0 whole tone
+ 5/4
+ 2/1
+`
+
+const testScalaKeyboardMapping = `! test.kbm
+5
+0
+127
+60
+60
+261.6255653005986
+! Repeat at the period of the scale
+0
+0
+x
+1
+2
+`
+
+func TestParseScala(t *testing.T) {
+	scale, err := ParseScalaScale(strings.NewReader(testScala))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{200, 1200 * math.Log2(1.25), 1200}
+	if scale.Description != "Test scale" || len(scale.Pitches) != len(want) {
+		t.Fatalf("got %+v", scale)
+	}
+	for i, cents := range want {
+		if math.Abs(scale.Pitches[i]-cents) > 1e-9 {
+			t.Errorf("pitch %d: got %v cents, want %v", i, scale.Pitches[i], cents)
+		}
+	}
+
+	mapping, err := ParseScalaKeyboardMapping(strings.NewReader(testScalaKeyboardMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.FirstNote != 0 || mapping.LastNote != 127 || mapping.MiddleNote != 60 || mapping.ReferenceNote != 60 || mapping.OctaveDegree != 0 {
+		t.Errorf("got %+v", mapping)
+	}
+	// The fifth entry is missing, so it is unmapped
+	wantMapping := []int{0, -1, 1, 2, -1}
+	if len(mapping.Mapping) != len(wantMapping) {
+		t.Fatalf("got mapping %v, want %v", mapping.Mapping, wantMapping)
+	}
+	for i := range wantMapping {
+		if mapping.Mapping[i] != wantMapping[i] {
+			t.Errorf("got mapping %v, want %v", mapping.Mapping, wantMapping)
+			break
+		}
+	}
+
+	tunings := NewMTSTuningFromScala(scale, mapping)
+	wantCents := map[Key]float64{
+		55: 4800,
+		57: 5000,
+		58: 4800 + 1200*math.Log2(1.25),
+		60: 6000,
+		62: 6200,
+		63: 6000 + 1200*math.Log2(1.25),
+		// Octave degree 0 moves the next cycle up a whole period
+		65: 7200,
+		67: 7400,
+	}
+	for key, cents := range wantCents {
+		if got := tunings[key].Cents(); math.Abs(got-cents) > 0.01 {
+			t.Errorf("key %d: got %v cents, want %v", key, got, cents)
+		}
+	}
+	for _, key := range []Key{56, 59, 61, 64, 66} {
+		if tunings[key] != MTSNoChange {
+			t.Errorf("unmapped key %d is tuned to %v", key, tunings[key])
+		}
+	}
+}
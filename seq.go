@@ -43,12 +43,25 @@ func (seq *Sequence) EncodeSMF(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	for _, mtrk := range seq.Tracks {
+	chunks := seq.Chunks
+	for i, mtrk := range seq.Tracks {
+		for ; len(chunks) != 0 && chunks[0].TrackIndex <= i; chunks = chunks[1:] {
+			err = chunks[0].EncodeSMF(w)
+			if err != nil {
+				return err
+			}
+		}
 		err = mtrk.EncodeSMF(w)
 		if err != nil {
 			return err
 		}
 	}
+	for _, chunk := range chunks {
+		err = chunk.EncodeSMF(w)
+		if err != nil {
+			return err
+		}
+	}
 	_, err = w.Write(seq.Undecoded)
 	return err
 }
@@ -56,9 +69,16 @@ func (seq *Sequence) EncodeSMF(w io.Writer) error {
 func (seq *Sequence) EncodeXML() *etree.Element {
 	el := etree.NewElement("Sequence")
 	el.AddChild(seq.Header.EncodeXML())
-	for _, mtrk := range seq.Tracks {
+	chunks := seq.Chunks
+	for i, mtrk := range seq.Tracks {
+		for ; len(chunks) != 0 && chunks[0].TrackIndex <= i; chunks = chunks[1:] {
+			el.AddChild(chunks[0].EncodeXML())
+		}
 		el.AddChild(mtrk.EncodeXML())
 	}
+	for _, chunk := range chunks {
+		el.AddChild(chunk.EncodeXML())
+	}
 	if len(seq.Undecoded) != 0 {
 		undecoded := etree.NewElement("Undecoded")
 		undecoded.SetText(fmt.Sprintf("% x", seq.Undecoded))
@@ -88,6 +108,7 @@ func DecodeSequenceFromSMF(r io.ReadSeeker, warningCallback WarningCallback) (se
 	defer func() {
 		seq.CalculateNotePair()
 		seq.CalculateTempoTable()
+		seq.CalculateMeterTable()
 	}()
 
 	pos := tell(r)
@@ -97,7 +118,10 @@ func DecodeSequenceFromSMF(r io.ReadSeeker, warningCallback WarningCallback) (se
 
 	for i := uint16(0); mthd.NTrks == 0 || i < mthd.NTrks; i++ {
 		var mtrk *MTrk
-		mtrk, err = DecodeMTrkFromSMF(r, warningCallback)
+		mtrk, err = decodeMTrkFromSMF(r, warningCallback, func(chunk *Chunk) {
+			chunk.TrackIndex = len(seq.Tracks)
+			seq.Chunks = append(seq.Chunks, chunk)
+		})
 		if mtrk != nil {
 			seq.Tracks = append(seq.Tracks, mtrk)
 		}
@@ -114,10 +138,13 @@ func DecodeSequenceFromSMF(r io.ReadSeeker, warningCallback WarningCallback) (se
 	}
 
 	pos = tell(r)
-	seq.Undecoded, err = ioutil.ReadAll(r)
+	trailing, err := ioutil.ReadAll(r)
 	if err != nil {
 		warningCallback(newSMFDecodeError(pos, err))
 	}
+	chunks, undecoded := decodeTrailingChunks(trailing, pos, len(seq.Tracks))
+	seq.Chunks = append(seq.Chunks, chunks...)
+	seq.Undecoded = undecoded
 	return
 }
 
@@ -138,6 +165,13 @@ func DecodeSequenceFromXML(el *etree.Element) (*Sequence, error) {
 					return nil, err
 				}
 				seq.Tracks = append(seq.Tracks, mtrk)
+			case "Chunk", "XFInfoHeader", "XFKaraokeHeader":
+				chunk, err := DecodeChunkFromXML(childEl)
+				if err != nil {
+					return nil, err
+				}
+				chunk.TrackIndex = len(seq.Tracks)
+				seq.Chunks = append(seq.Chunks, chunk)
 			case "Undecoded":
 				var err error
 				seq.Undecoded, err = parseHexDump(childEl.Text())
@@ -154,6 +188,7 @@ func DecodeSequenceFromXML(el *etree.Element) (*Sequence, error) {
 	}
 	seq.CalculateNotePair()
 	seq.CalculateTempoTable()
+	seq.CalculateMeterTable()
 	return seq, nil
 }
 
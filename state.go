@@ -0,0 +1,203 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"sort"
+)
+
+// ChannelState is what a device remembers about a MIDI channel: program,
+// controllers, pitch bend, channel pressure and parameter numbers.
+// Controllers and ChannelPressure are -1 if never set, PitchBend is
+// PitchBendUnset if never set, Program is 0 if never set, and is 1-based as in
+// EventProgramChange.
+type ChannelState struct {
+	Channel         uint8
+	Active          bool
+	Program         uint8
+	Controllers     [128]int16
+	PitchBend       int16
+	ChannelPressure int16
+	RPN             map[uint16]uint16
+	NRPN            map[uint16]uint16
+	decoder         *ControllerDecoder
+}
+
+// PitchBend of a ChannelState that has never received a Pitch Wheel Change
+const PitchBendUnset int16 = -0x8000
+
+func NewChannelState(channel uint8) *ChannelState {
+	state := &ChannelState{
+		Channel:         channel,
+		PitchBend:       PitchBendUnset,
+		ChannelPressure: -1,
+		RPN:             make(map[uint16]uint16),
+		NRPN:            make(map[uint16]uint16),
+		decoder:         NewControllerDecoder(),
+	}
+	for i := range state.Controllers {
+		state.Controllers[i] = -1
+	}
+	return state
+}
+
+// Returns the state of every channel right before a tick, channel 1 first
+func (seq *Sequence) ChannelStatesAt(absTick int64) [16]*ChannelState {
+	var states [16]*ChannelState
+	for i := range states {
+		states[i] = NewChannelState(uint8(i + 1))
+	}
+	it := newEventIterator(seq)
+	for {
+		_, event, eventTick, ok := it.next()
+		if !ok {
+			break
+		}
+		if eventTick >= absTick {
+			if seq.Header.Format == 2 {
+				continue
+			}
+			break
+		}
+		if channel := event.Common().Channel; channel-1 < 16 {
+			states[channel-1].Update(event)
+		}
+	}
+	return states
+}
+
+// Applies an event to the state, events of other channels are ignored
+func (state *ChannelState) Update(event Event) {
+	if event.Common().Channel != state.Channel || !isChannelEvent(event) {
+		return
+	}
+	state.Active = true
+	switch ev := event.(type) {
+	case *EventControlChange:
+		if change, ok := state.decoder.Decode(ev); ok {
+			switch change.Kind {
+			case ControllerRPN:
+				state.RPN[change.Number] = change.Value
+			case ControllerNRPN:
+				state.NRPN[change.Number] = change.Value
+			}
+		}
+		switch ev.Control {
+		case 6, 38, 96, 97, 98, 99, 100, 101:
+			// Parameter numbers are tracked by value instead
+		case 121:
+			// Reset All Controllers, as recommended by RP-015
+			for _, control := range []uint8{1, 64, 65, 66, 67} {
+				state.Controllers[control] = 0
+			}
+			state.Controllers[11] = 127
+			state.PitchBend = 0
+			state.ChannelPressure = 0
+		default:
+			if ev.Control < 120 {
+				state.Controllers[ev.Control] = int16(ev.Value & 0x7f)
+			}
+		}
+	case *EventProgramChange:
+		state.Program = ev.Program
+	case *EventPitchWheelChange:
+		state.PitchBend = ev.Pitch
+	case *EventChannelPressure:
+		state.ChannelPressure = int16(ev.Velocity & 0x7f)
+	}
+}
+
+// Returns the events that bring a device into this state: bank select and
+// program change first, then controllers, parameter numbers, pitch bend and
+// channel pressure.
+// Nothing is returned for a channel that has never been used.
+func (state *ChannelState) Events() []Event {
+	if !state.Active {
+		return nil
+	}
+	events := make([]Event, 0)
+	common := EventCommon{
+		Channel: state.Channel,
+	}
+	addControl := func(control uint8) {
+		if state.Controllers[control] >= 0 {
+			events = append(events, &EventControlChange{
+				EventCommon: common,
+				Control:     control,
+				Value:       uint8(state.Controllers[control]),
+			})
+		}
+	}
+	addControl(0)
+	addControl(32)
+	if state.Program != 0 {
+		events = append(events, &EventProgramChange{
+			EventCommon: common,
+			Program:     state.Program,
+		})
+	}
+	for control := uint8(1); control < 120; control++ {
+		if control != 32 {
+			addControl(control)
+		}
+	}
+	addParameters := func(kind ControllerKind, values map[uint16]uint16) {
+		numbers := make([]int, 0, len(values))
+		for number := range values {
+			numbers = append(numbers, int(number))
+		}
+		sort.Ints(numbers)
+		for _, number := range numbers {
+			change := &ControllerChange{
+				EventCommon: common,
+				Kind:        kind,
+				Number:      uint16(number),
+				Value:       values[uint16(number)],
+			}
+			for _, ev := range change.ControlChanges() {
+				events = append(events, Event(ev))
+			}
+		}
+	}
+	addParameters(ControllerRPN, state.RPN)
+	addParameters(ControllerNRPN, state.NRPN)
+	if len(state.RPN) != 0 || len(state.NRPN) != 0 {
+		// Deselect the parameter so stray Data Entry events do no harm
+		events = append(events, &EventControlChange{EventCommon: common, Control: 101, Value: 0x7f}, &EventControlChange{EventCommon: common, Control: 100, Value: 0x7f})
+	}
+	if state.PitchBend != PitchBendUnset {
+		events = append(events, &EventPitchWheelChange{
+			EventCommon: common,
+			Pitch:       state.PitchBend,
+		})
+	}
+	if state.ChannelPressure >= 0 {
+		events = append(events, &EventChannelPressure{
+			EventCommon: common,
+			Velocity:    uint8(state.ChannelPressure),
+		})
+	}
+	return events
+}
@@ -0,0 +1,87 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChannelStatesAt(t *testing.T) {
+	data := buildTestSMF(buildTestMTrk(
+		0x00, 0xc0, 0x05,
+		0x00, 0xb0, 0x07, 0x64,
+		0x00, 0xb0, 0x65, 0x00,
+		0x00, 0xb0, 0x64, 0x00,
+		0x00, 0xb0, 0x06, 0x0c,
+		0x0a, 0xe0, 0x00, 0x50,
+		0x0a, 0xb0, 0x79, 0x00,
+		// Data Entry after Reset All Controllers has no parameter selected
+		0x0a, 0xb0, 0x06, 0x02,
+		0x0a, 0xff, 0x2f, 0x00,
+	), testMTrk)
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasPitchBend := func(events []Event) bool {
+		for _, event := range events {
+			if _, ok := event.(*EventPitchWheelChange); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	states := seq.ChannelStatesAt(0)
+	if states[0].Active || states[0].Events() != nil {
+		t.Errorf("channel 1 is active before its first event")
+	}
+
+	states = seq.ChannelStatesAt(10)
+	state := states[0]
+	if !state.Active || state.Program != 6 || state.Controllers[7] != 100 || state.RPN[RPNPitchBendSensitivity] != 12<<7 {
+		t.Errorf("tick 10: got %+v", state)
+	}
+	if state.PitchBend != PitchBendUnset || hasPitchBend(state.Events()) {
+		t.Errorf("tick 10: pitch bend %d emitted before any Pitch Wheel Change", state.PitchBend)
+	}
+	if states[1].Active || states[1].Events() != nil {
+		t.Errorf("tick 10: unused channel 2 has events %v", states[1].Events())
+	}
+
+	state = seq.ChannelStatesAt(20)[0]
+	if state.PitchBend != 2048 || !hasPitchBend(state.Events()) {
+		t.Errorf("tick 20: pitch bend %d not emitted", state.PitchBend)
+	}
+
+	state = seq.ChannelStatesAt(50)[0]
+	if state.PitchBend != 0 || state.Controllers[11] != 127 || state.Controllers[7] != 100 {
+		t.Errorf("tick 50: Reset All Controllers gave %+v", state)
+	}
+	if state.RPN[RPNPitchBendSensitivity] != 12<<7 {
+		t.Errorf("tick 50: Data Entry after Reset All Controllers changed RPN 0 to %#x", state.RPN[RPNPitchBendSensitivity])
+	}
+}
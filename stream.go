@@ -0,0 +1,101 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// The decoders never seek back more than a few bytes, so keeping a short
+// history of what has been read is enough to run them on a plain io.Reader.
+const streamHistorySize = 64
+
+type StreamReadSeeker struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+	off  int
+}
+
+func NewStreamReadSeeker(r io.Reader) *StreamReadSeeker {
+	return &StreamReadSeeker{
+		r:   r,
+		buf: make([]byte, 0, streamHistorySize*2),
+	}
+}
+
+func DecodeSequenceFromStream(r io.Reader, warningCallback WarningCallback) (*Sequence, error) {
+	return DecodeSequenceFromSMF(NewStreamReadSeeker(r), warningCallback)
+}
+
+func (s *StreamReadSeeker) Read(p []byte) (n int, err error) {
+	if s.off < len(s.buf) {
+		n = copy(p, s.buf[s.off:])
+		s.off += n
+		return n, nil
+	}
+	n, err = s.r.Read(p)
+	s.remember(p[:n])
+	return
+}
+
+func (s *StreamReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos := s.base + int64(s.off)
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pos
+	default:
+		return pos, errors.New("midimark: can not seek relative to the end of a stream")
+	}
+	if offset < s.base {
+		return pos, fmt.Errorf("midimark: can not seek back to %#x in a stream", offset)
+	}
+	end := s.base + int64(len(s.buf))
+	if offset <= end {
+		s.off = int(offset - s.base)
+		return offset, nil
+	}
+	s.off = len(s.buf)
+	_, err := io.CopyN(ioutil.Discard, s, offset-end)
+	return s.base + int64(s.off), err
+}
+
+func (s *StreamReadSeeker) remember(data []byte) {
+	if len(data) >= streamHistorySize {
+		s.base += int64(len(s.buf) + len(data) - streamHistorySize)
+		s.buf = append(s.buf[:0], data[len(data)-streamHistorySize:]...)
+	} else {
+		s.buf = append(s.buf, data...)
+		if extra := len(s.buf) - streamHistorySize; extra > 0 {
+			s.base += int64(extra)
+			s.buf = s.buf[:copy(s.buf, s.buf[extra:])]
+		}
+	}
+	s.off = len(s.buf)
+}
@@ -0,0 +1,97 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeStreamMatchesSeeker(t *testing.T) {
+	longSysEx := make([]byte, 100)
+	for i := range longSysEx {
+		longSysEx[i] = uint8(i)
+	}
+	track1 := []byte{
+		0x00, 0xff, 0x01, 0x04, 'T', 'e', 's', 't',
+		0x00, 0x90, 0x3c, 0x64,
+		// Running status
+		0x10, 0x3e, 0x64,
+		0x10, 0x3c, 0x00,
+		0x00, 0xf0, byte(len(longSysEx) + 1),
+	}
+	track1 = append(track1, longSysEx...)
+	track1 = append(track1, 0xf7, 0x10, 0x3e, 0x00, 0x00, 0xff, 0x2f, 0x00)
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 3, 0, 96}
+	data = append(data, buildTestMTrk(track1...)...)
+	data = append(data, 'A', 'B', 'C', 'D', 0, 0, 0, 3, 1, 2, 3)
+	// Garbage before a track with no length
+	data = append(data, 0x00, 0x01, 0x02)
+	data = append(data, 'M', 'T', 'r', 'k', 0, 0, 0, 0, 0x00, 0xc0, 0x05, 0x00, 0xff, 0x2f, 0x00)
+	data = append(data, testMTrk...)
+	data = append(data, 'X', 'T', 'R', 'A', 0, 0, 0, 1, 9, 0xff)
+
+	decode := func(r io.ReadSeeker) (string, []string, error) {
+		var warnings []string
+		seq, err := DecodeSequenceFromSMF(r, func(err error) {
+			warnings = append(warnings, err.Error())
+		})
+		if seq == nil {
+			return "", warnings, err
+		}
+		var buf bytes.Buffer
+		_, xmlErr := seq.EncodeXMLToDocument(&buf)
+		if xmlErr != nil {
+			t.Fatal(xmlErr)
+		}
+		return buf.String(), warnings, err
+	}
+	wantXML, wantWarnings, wantErr := decode(bytes.NewReader(data))
+	if len(wantWarnings) == 0 {
+		t.Fatal("test file decoded without warnings")
+	}
+	readers := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"stream", struct{ io.Reader }{bytes.NewReader(data)}},
+		{"one byte stream", iotest.OneByteReader(bytes.NewReader(data))},
+	}
+	for _, reader := range readers {
+		gotXML, gotWarnings, gotErr := decode(NewStreamReadSeeker(reader.r))
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+			t.Errorf("%s: got error %v, want %v", reader.name, gotErr, wantErr)
+		}
+		if gotXML != wantXML {
+			t.Errorf("%s: decoded to\n%s\nwant\n%s", reader.name, gotXML, wantXML)
+		}
+		if fmt.Sprint(gotWarnings) != fmt.Sprint(wantWarnings) {
+			t.Errorf("%s: got warnings %q, want %q", reader.name, gotWarnings, wantWarnings)
+		}
+	}
+}
@@ -47,8 +47,10 @@ type MetaEvent interface {
 }
 
 type Sequence struct {
-	Header    *MThd
-	Tracks    []*MTrk
+	Header *MThd
+	Tracks []*MTrk
+	// Chunks other than MThd and MTrk, in their original order
+	Chunks    []*Chunk
 	Undecoded []byte
 }
 
@@ -64,9 +66,19 @@ type MThd struct {
 type MTrk struct {
 	FilePosition int64
 	TempoTable   *TempoTable
+	MeterTable   *MeterTable
 	Events       []Event
 }
 
+// A chunk of unknown type, such as XFIH
+type Chunk struct {
+	FilePosition int64
+	Type         [4]byte
+	Data         []byte
+	// The chunk is placed before this track, or after the last track
+	TrackIndex int
+}
+
 type EventCommon struct {
 	FilePosition int64
 	AbsTick      int64
@@ -332,6 +344,26 @@ type TempoChange struct {
 	UsPerQuarter uint32
 }
 
+type MeterTable struct {
+	Framerate uint8
+	Division  uint16
+	Changes   []MeterChange
+}
+
+type MeterChange struct {
+	AbsTick      int64
+	FilePosition int64
+	Numerator    uint8
+	Denominator  uint8
+	Bar          int64
+}
+
+type MusicalPosition struct {
+	Bar  int64
+	Beat int64
+	Tick int64
+}
+
 func (ev *EventCommon) Common() *EventCommon {
 	return ev
 }
@@ -0,0 +1,470 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+var ErrSysExChecksum = errors.New("midimark: system exclusive checksum mismatch")
+
+// SysEx is a System Exclusive message of a known kind
+type SysEx interface {
+	// Bytes following F0, including the trailing F7, as in EventSystemExclusive.Data
+	SysExData() []byte
+	sysexTag() string
+	encodeXMLAttr(el *etree.Element)
+}
+
+// Universal Non-Realtime 7E dev 09 01
+type SysExGMSystemOn struct {
+	DeviceID uint8
+}
+
+// Universal Non-Realtime 7E dev 09 02
+type SysExGMSystemOff struct {
+	DeviceID uint8
+}
+
+// Universal Non-Realtime 7E dev 09 03
+type SysExGM2SystemOn struct {
+	DeviceID uint8
+}
+
+// Roland 41 dev 42 12 40 00 7F 00 41
+type SysExGSReset struct {
+	DeviceID uint8
+}
+
+// Roland 41 dev model 12 addr addr addr data... sum
+type SysExGSDataSet struct {
+	DeviceID uint8
+	ModelID  uint8
+	Address  uint32
+	Data     []byte
+}
+
+// Yamaha 43 1n 4C 00 00 7E 00
+type SysExXGSystemOn struct {
+	DeviceNumber uint8
+}
+
+// Yamaha 43 1n 4C addr addr addr data...
+type SysExXGParameterChange struct {
+	DeviceNumber uint8
+	Address      uint32
+	Data         []byte
+}
+
+// Universal Realtime 7F dev 04 01
+type SysExMasterVolume struct {
+	DeviceID uint8
+	Volume   uint16
+}
+
+// Universal Realtime 7F dev 04 02
+type SysExMasterBalance struct {
+	DeviceID uint8
+	Balance  uint16
+}
+
+// Universal Realtime 7F dev 04 03, 0x2000 is A440
+type SysExMasterFineTuning struct {
+	DeviceID uint8
+	Tuning   uint16
+}
+
+// Universal Realtime 7F dev 04 04, 0x40 is A440
+type SysExMasterCoarseTuning struct {
+	DeviceID uint8
+	Tuning   uint8
+}
+
+// Recognizes a System Exclusive message.
+// Returns nil if the message is of an unknown kind.
+func DecodeSysEx(data []byte) (SysEx, error) {
+	if len(data) == 0 || data[len(data)-1] != 0xf7 {
+		return nil, nil
+	}
+	body := data[:len(data)-1]
+	switch {
+	case len(body) == 4 && body[0] == 0x7e && body[2] == 0x09:
+		switch body[3] {
+		case 0x01:
+			return &SysExGMSystemOn{DeviceID: body[1]}, nil
+		case 0x02:
+			return &SysExGMSystemOff{DeviceID: body[1]}, nil
+		case 0x03:
+			return &SysExGM2SystemOn{DeviceID: body[1]}, nil
+		}
+	case len(body) >= 8 && body[0] == 0x41 && (body[2] == 0x42 || body[2] == 0x45) && body[3] == 0x12:
+		if rolandChecksum(body[4:len(body)-1]) != body[len(body)-1] {
+			return nil, ErrSysExChecksum
+		}
+		address := uint32(body[4])<<16 | uint32(body[5])<<8 | uint32(body[6])
+		payload := body[7 : len(body)-1]
+		if body[2] == 0x42 && address == 0x40007f && len(payload) == 1 && payload[0] == 0x00 {
+			return &SysExGSReset{DeviceID: body[1]}, nil
+		}
+		return &SysExGSDataSet{
+			DeviceID: body[1],
+			ModelID:  body[2],
+			Address:  address,
+			Data:     append([]byte(nil), payload...),
+		}, nil
+	case len(body) >= 7 && body[0] == 0x43 && body[1]&0xf0 == 0x10 && body[2] == 0x4c:
+		address := uint32(body[3])<<16 | uint32(body[4])<<8 | uint32(body[5])
+		payload := body[6:]
+		if address == 0x00007e && len(payload) == 1 && payload[0] == 0x00 {
+			return &SysExXGSystemOn{DeviceNumber: body[1] & 0x0f}, nil
+		}
+		return &SysExXGParameterChange{
+			DeviceNumber: body[1] & 0x0f,
+			Address:      address,
+			Data:         append([]byte(nil), payload...),
+		}, nil
+	case len(body) >= 4 && (body[0] == 0x7e || body[0] == 0x7f) && body[2] == 0x08:
+		return decodeMTS(body)
+	case len(body) >= 4 && body[0] == 0x7e:
+		return decodeUniversalNonRealtime(body)
+	case len(body) >= 4 && body[0] == 0x7f && body[2] == 0x06:
+		return decodeMMC(body)
+	case len(body) == 6 && body[0] == 0x7f && body[2] == 0x04:
+		value := uint16(body[5]&0x7f)<<7 | uint16(body[4]&0x7f)
+		switch body[3] {
+		case 0x01:
+			return &SysExMasterVolume{DeviceID: body[1], Volume: value}, nil
+		case 0x02:
+			return &SysExMasterBalance{DeviceID: body[1], Balance: value}, nil
+		case 0x03:
+			return &SysExMasterFineTuning{DeviceID: body[1], Tuning: value}, nil
+		case 0x04:
+			if body[4] == 0x00 {
+				return &SysExMasterCoarseTuning{DeviceID: body[1], Tuning: body[5]}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Decodes the sysex tag of the markup, returns nil if the tag is not a sysex tag
+func decodeSysExFromXML(el *etree.Element) (SysEx, error) {
+	attr := func(name string, bitSize int) (uint64, error) {
+		value, err := strconv.ParseUint(el.SelectAttrValue(name, ""), 0, bitSize)
+		if err != nil {
+			return 0, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+		}
+		return value, nil
+	}
+	hexAttr := func(name string) ([]byte, error) {
+		data, err := parseHexDump(el.SelectAttrValue(name, ""))
+		if err != nil {
+			return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+		}
+		return data, nil
+	}
+	switch el.Tag {
+	case "GMSystemOn", "GMSystemOff", "GM2SystemOn", "GSReset":
+		deviceID, err := attr("device", 7)
+		if err != nil {
+			return nil, err
+		}
+		switch el.Tag {
+		case "GMSystemOn":
+			return &SysExGMSystemOn{DeviceID: uint8(deviceID)}, nil
+		case "GMSystemOff":
+			return &SysExGMSystemOff{DeviceID: uint8(deviceID)}, nil
+		case "GM2SystemOn":
+			return &SysExGM2SystemOn{DeviceID: uint8(deviceID)}, nil
+		default:
+			return &SysExGSReset{DeviceID: uint8(deviceID)}, nil
+		}
+	case "GSDataSet":
+		deviceID, err := attr("device", 7)
+		if err != nil {
+			return nil, err
+		}
+		modelID, err := attr("model", 7)
+		if err != nil {
+			return nil, err
+		}
+		address, err := attr("address", 24)
+		if err != nil {
+			return nil, err
+		}
+		data, err := hexAttr("data")
+		if err != nil {
+			return nil, err
+		}
+		return &SysExGSDataSet{
+			DeviceID: uint8(deviceID),
+			ModelID:  uint8(modelID),
+			Address:  uint32(address),
+			Data:     data,
+		}, nil
+	case "XGSystemOn":
+		deviceNumber, err := attr("device", 4)
+		if err != nil {
+			return nil, err
+		}
+		return &SysExXGSystemOn{DeviceNumber: uint8(deviceNumber)}, nil
+	case "XGParameterChange":
+		deviceNumber, err := attr("device", 4)
+		if err != nil {
+			return nil, err
+		}
+		address, err := attr("address", 24)
+		if err != nil {
+			return nil, err
+		}
+		data, err := hexAttr("data")
+		if err != nil {
+			return nil, err
+		}
+		return &SysExXGParameterChange{
+			DeviceNumber: uint8(deviceNumber),
+			Address:      uint32(address),
+			Data:         data,
+		}, nil
+	case "MasterVolume", "MasterBalance", "MasterFineTuning":
+		deviceID, err := attr("device", 7)
+		if err != nil {
+			return nil, err
+		}
+		value, err := attr("value", 14)
+		if err != nil {
+			return nil, err
+		}
+		switch el.Tag {
+		case "MasterVolume":
+			return &SysExMasterVolume{DeviceID: uint8(deviceID), Volume: uint16(value)}, nil
+		case "MasterBalance":
+			return &SysExMasterBalance{DeviceID: uint8(deviceID), Balance: uint16(value)}, nil
+		default:
+			return &SysExMasterFineTuning{DeviceID: uint8(deviceID), Tuning: uint16(value)}, nil
+		}
+	case "MasterCoarseTuning":
+		deviceID, err := attr("device", 7)
+		if err != nil {
+			return nil, err
+		}
+		value, err := attr("value", 7)
+		if err != nil {
+			return nil, err
+		}
+		return &SysExMasterCoarseTuning{DeviceID: uint8(deviceID), Tuning: uint8(value)}, nil
+	case "MTSBulkDump", "MTSNoteChange", "MTSScaleOctave1Byte", "MTSScaleOctave2Byte":
+		return decodeMTSFromXML(el)
+	case "IdentityRequest", "IdentityReply", "SampleDumpHeader", "Handshake":
+		return decodeUniversalFromXML(el)
+	case "MMC", "MMCStop", "MMCPlay", "MMCDeferredPlay", "MMCFastForward", "MMCRewind", "MMCRecordStrobe", "MMCRecordExit", "MMCRecordPause", "MMCPause", "MMCEject", "MMCChase", "MMCReset", "MMCLocate":
+		return decodeMMCFromXML(el)
+	}
+	return nil, nil
+}
+
+func (ev *EventSystemExclusive) SysEx() (SysEx, error) {
+	return DecodeSysEx(ev.Data)
+}
+
+// Recognizes an escape sequence that carries a complete F0 ... F7 message
+func (ev *EventEscape) SysEx() (SysEx, error) {
+	if len(ev.Data) == 0 || ev.Data[0] != 0xf0 {
+		return nil, nil
+	}
+	return DecodeSysEx(ev.Data[1:])
+}
+
+// Returns the known kind of a message, or nil if it can not be represented
+// exactly by one
+func decodeExactSysEx(data []byte) SysEx {
+	sysex, err := DecodeSysEx(data)
+	if err != nil || sysex == nil || !bytes.Equal(sysex.SysExData(), data) {
+		return nil
+	}
+	return sysex
+}
+
+func rolandChecksum(data []byte) uint8 {
+	sum := uint8(0)
+	for _, b := range data {
+		sum += b
+	}
+	return (0x80 - sum&0x7f) & 0x7f
+}
+
+func (sysex *SysExGMSystemOn) SysExData() []byte {
+	return []byte{0x7e, sysex.DeviceID & 0x7f, 0x09, 0x01, 0xf7}
+}
+
+func (sysex *SysExGMSystemOn) sysexTag() string {
+	return "GMSystemOn"
+}
+
+func (sysex *SysExGMSystemOn) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+}
+
+func (sysex *SysExGMSystemOff) SysExData() []byte {
+	return []byte{0x7e, sysex.DeviceID & 0x7f, 0x09, 0x02, 0xf7}
+}
+
+func (sysex *SysExGMSystemOff) sysexTag() string {
+	return "GMSystemOff"
+}
+
+func (sysex *SysExGMSystemOff) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+}
+
+func (sysex *SysExGM2SystemOn) SysExData() []byte {
+	return []byte{0x7e, sysex.DeviceID & 0x7f, 0x09, 0x03, 0xf7}
+}
+
+func (sysex *SysExGM2SystemOn) sysexTag() string {
+	return "GM2SystemOn"
+}
+
+func (sysex *SysExGM2SystemOn) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+}
+
+func (sysex *SysExGSReset) SysExData() []byte {
+	return []byte{0x41, sysex.DeviceID & 0x7f, 0x42, 0x12, 0x40, 0x00, 0x7f, 0x00, 0x41, 0xf7}
+}
+
+func (sysex *SysExGSReset) sysexTag() string {
+	return "GSReset"
+}
+
+func (sysex *SysExGSReset) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+}
+
+func (sysex *SysExGSDataSet) SysExData() []byte {
+	data := make([]byte, 0, len(sysex.Data)+9)
+	data = append(data, 0x41, sysex.DeviceID&0x7f, sysex.ModelID&0x7f, 0x12, uint8(sysex.Address>>16)&0x7f, uint8(sysex.Address>>8)&0x7f, uint8(sysex.Address)&0x7f)
+	data = append(data, sysex.Data...)
+	data = append(data, rolandChecksum(data[4:]), 0xf7)
+	return data
+}
+
+func (sysex *SysExGSDataSet) sysexTag() string {
+	return "GSDataSet"
+}
+
+func (sysex *SysExGSDataSet) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("model", fmt.Sprintf("%#02x", sysex.ModelID))
+	el.CreateAttr("address", fmt.Sprintf("%#06x", sysex.Address))
+	el.CreateAttr("data", fmt.Sprintf("% x", sysex.Data))
+}
+
+func (sysex *SysExXGSystemOn) SysExData() []byte {
+	return []byte{0x43, 0x10 | sysex.DeviceNumber&0x0f, 0x4c, 0x00, 0x00, 0x7e, 0x00, 0xf7}
+}
+
+func (sysex *SysExXGSystemOn) sysexTag() string {
+	return "XGSystemOn"
+}
+
+func (sysex *SysExXGSystemOn) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceNumber))
+}
+
+func (sysex *SysExXGParameterChange) SysExData() []byte {
+	data := make([]byte, 0, len(sysex.Data)+7)
+	data = append(data, 0x43, 0x10|sysex.DeviceNumber&0x0f, 0x4c, uint8(sysex.Address>>16)&0x7f, uint8(sysex.Address>>8)&0x7f, uint8(sysex.Address)&0x7f)
+	data = append(data, sysex.Data...)
+	data = append(data, 0xf7)
+	return data
+}
+
+func (sysex *SysExXGParameterChange) sysexTag() string {
+	return "XGParameterChange"
+}
+
+func (sysex *SysExXGParameterChange) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceNumber))
+	el.CreateAttr("address", fmt.Sprintf("%#06x", sysex.Address))
+	el.CreateAttr("data", fmt.Sprintf("% x", sysex.Data))
+}
+
+func (sysex *SysExMasterVolume) SysExData() []byte {
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x04, 0x01, uint8(sysex.Volume) & 0x7f, uint8(sysex.Volume>>7) & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMasterVolume) sysexTag() string {
+	return "MasterVolume"
+}
+
+func (sysex *SysExMasterVolume) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("value", fmt.Sprintf("%d", sysex.Volume))
+}
+
+func (sysex *SysExMasterBalance) SysExData() []byte {
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x04, 0x02, uint8(sysex.Balance) & 0x7f, uint8(sysex.Balance>>7) & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMasterBalance) sysexTag() string {
+	return "MasterBalance"
+}
+
+func (sysex *SysExMasterBalance) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("value", fmt.Sprintf("%d", sysex.Balance))
+}
+
+func (sysex *SysExMasterFineTuning) SysExData() []byte {
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x04, 0x03, uint8(sysex.Tuning) & 0x7f, uint8(sysex.Tuning>>7) & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMasterFineTuning) sysexTag() string {
+	return "MasterFineTuning"
+}
+
+func (sysex *SysExMasterFineTuning) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("value", fmt.Sprintf("%d", sysex.Tuning))
+}
+
+func (sysex *SysExMasterCoarseTuning) SysExData() []byte {
+	return []byte{0x7f, sysex.DeviceID & 0x7f, 0x04, 0x04, 0x00, sysex.Tuning & 0x7f, 0xf7}
+}
+
+func (sysex *SysExMasterCoarseTuning) sysexTag() string {
+	return "MasterCoarseTuning"
+}
+
+func (sysex *SysExMasterCoarseTuning) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("value", fmt.Sprintf("%d", sysex.Tuning))
+}
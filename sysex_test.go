@@ -0,0 +1,78 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+func TestSysExDeviceXMLAttr(t *testing.T) {
+	tests := []struct {
+		sysex  SysEx
+		device string
+	}{
+		{&SysExGMSystemOn{DeviceID: 0x10}, "0x10"},
+		{&SysExGSReset{DeviceID: 0x10}, "0x10"},
+		{&SysExMasterVolume{DeviceID: 0x7f, Volume: 0x3fff}, "0x7f"},
+		{&SysExXGSystemOn{DeviceNumber: 0x0a}, "0x0a"},
+		{&SysExXGParameterChange{DeviceNumber: 0x0a, Address: 0x080000, Data: []byte{0x7f}}, "0x0a"},
+	}
+	for _, test := range tests {
+		ev := &EventSystemExclusive{Data: test.sysex.SysExData()}
+		el := ev.EncodeXML()
+		if got := el.SelectAttrValue("device", ""); got != test.device {
+			t.Errorf("%s: got device=%q, want %q", el.Tag, got, test.device)
+		}
+		doc := etree.NewDocument()
+		doc.SetRoot(el)
+		decoded, err := decodeSysExFromXML(doc.Root())
+		if err != nil {
+			t.Errorf("%s: %v", el.Tag, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, test.sysex) {
+			t.Errorf("%s: decoded %+v, want %+v", el.Tag, decoded, test.sysex)
+		}
+	}
+}
+
+// Checks a SysEx encodes to data, and data decodes back to it
+func checkSysExRoundTrip(t *testing.T, sysex SysEx, data []byte) {
+	if got := sysex.SysExData(); !bytes.Equal(got, data) {
+		t.Errorf("%+v: encoded % x, want % x", sysex, got, data)
+	}
+	decoded, err := DecodeSysEx(data)
+	if err != nil {
+		t.Errorf("% x: %v", data, err)
+		return
+	}
+	if !reflect.DeepEqual(decoded, sysex) {
+		t.Errorf("% x: decoded %+v, want %+v", data, decoded, sysex)
+	}
+}
@@ -104,7 +104,7 @@ func (seq *Sequence) CalculateNotePair() {
 					noteOn[ev.Channel-1][ev.Key] = noteOn[ev.Channel-1][ev.Key][:len(noteOn[ev.Channel-1][ev.Key])-1]
 				case *EventNoteOn:
 					ev.RelatedNoteOff = nil
-					if ev.Channel-1 >= 16 || ev.Key >= 0x80 || len(noteOn[ev.Channel-1][ev.Key]) == 0 {
+					if ev.Channel-1 >= 16 || ev.Key >= 0x80 {
 						break
 					}
 					noteOn[ev.Channel-1][ev.Key] = append(noteOn[ev.Channel-1][ev.Key], ev)
@@ -120,24 +120,12 @@ func (seq *Sequence) CalculateNotePair() {
 		}
 	} else {
 		var noteOn [16][128][]*EventNoteOn
-		i := make([]int, len(seq.Tracks))
-		absTick := make([]uint64, len(seq.Tracks))
+		it := newEventIterator(seq)
 		for {
-			var selectedEvent Event
-			selectedTrack := 0
-			selectedTick := uint64(0)
-			for t, mtrk := range seq.Tracks {
-				if i[t] < len(mtrk.Events) && (selectedEvent == nil || absTick[t]+uint64(mtrk.Events[i[t]].Common().DeltaTick) < selectedTick) {
-					selectedEvent = mtrk.Events[i[t]]
-					selectedTrack = t
-					selectedTick = absTick[t] + uint64(selectedEvent.Common().DeltaTick)
-					absTick[t] = selectedTick
-				}
-			}
-			if selectedEvent == nil {
+			_, selectedEvent, _, ok := it.next()
+			if !ok {
 				break
 			}
-			i[selectedTrack]++
 
 			switch ev := selectedEvent.(type) {
 			case *EventNoteOff:
@@ -198,27 +186,76 @@ func (mtrk *MTrk) ConvertAbsTickToDuration(absTick int64) time.Duration {
 	if mtrk.TempoTable == nil {
 		panic(errors.New("midimark: track does not contain a tempo table"))
 	}
-	if mtrk.TempoTable.Framerate != 0 {
-		if mtrk.TempoTable.Framerate == 29 {
-			return time.Duration(absTick) * (time.Second * 100) / (time.Duration(mtrk.TempoTable.Division) * 2997)
+	return mtrk.TempoTable.ConvertAbsTickToDuration(absTick)
+}
+
+func (mtrk *MTrk) ConvertDurationToAbsTick(duration time.Duration) int64 {
+	if mtrk.TempoTable == nil {
+		panic(errors.New("midimark: track does not contain a tempo table"))
+	}
+	return mtrk.TempoTable.ConvertDurationToAbsTick(duration)
+}
+
+func (table *TempoTable) ConvertAbsTickToDuration(absTick int64) time.Duration {
+	if table.Framerate != 0 {
+		if table.Framerate == 29 {
+			// 30000 frames last exactly 1001 seconds, split the value to avoid overflow
+			ticksPerUnit := int64(table.Division) * 30000
+			return time.Duration(absTick/ticksPerUnit)*1001*time.Second + time.Duration(absTick%ticksPerUnit*int64(time.Second*1001)/ticksPerUnit)
 		} else {
-			return time.Duration(absTick) * time.Second / (time.Duration(mtrk.TempoTable.Division) * time.Duration(mtrk.TempoTable.Framerate))
+			return time.Duration(absTick) * time.Second / (time.Duration(table.Division) * time.Duration(table.Framerate))
 		}
 	}
 	lastChange := int64(0)
 	numerator := int64(0)
-	denominator := mtrk.TempoTable.Division
+	denominator := table.Division
 	usPerQuarter := uint32(500000)
-	if len(mtrk.TempoTable.Changes) != 0 {
-		if mtrk.TempoTable.Changes[0].AbsTick < lastChange {
-			lastChange = mtrk.TempoTable.Changes[0].AbsTick
+	if len(table.Changes) != 0 {
+		if table.Changes[0].AbsTick < lastChange {
+			lastChange = table.Changes[0].AbsTick
 		}
-		for i := 0; i < len(mtrk.TempoTable.Changes) && mtrk.TempoTable.Changes[i].AbsTick <= absTick; i++ {
-			numerator += (mtrk.TempoTable.Changes[i].AbsTick - lastChange) * int64(usPerQuarter)
-			lastChange = mtrk.TempoTable.Changes[i].AbsTick
-			usPerQuarter = mtrk.TempoTable.Changes[i].UsPerQuarter
+		for i := 0; i < len(table.Changes) && table.Changes[i].AbsTick <= absTick; i++ {
+			numerator += (table.Changes[i].AbsTick - lastChange) * int64(usPerQuarter)
+			lastChange = table.Changes[i].AbsTick
+			usPerQuarter = table.Changes[i].UsPerQuarter
 		}
 	}
 	numerator += (absTick - lastChange) * int64(usPerQuarter)
 	return time.Duration(numerator) * time.Microsecond / time.Duration(denominator)
 }
+
+// Returns the tick nearest to the given duration
+func (table *TempoTable) ConvertDurationToAbsTick(duration time.Duration) int64 {
+	if table.Framerate != 0 {
+		unit := int64(time.Second)
+		ticksPerUnit := int64(table.Division) * int64(table.Framerate)
+		if table.Framerate == 29 {
+			unit = int64(time.Second * 1001)
+			ticksPerUnit = int64(table.Division) * 30000
+		}
+		// Split the duration to avoid overflow on long durations
+		return int64(duration)/unit*ticksPerUnit + divRound(int64(duration)%unit*ticksPerUnit, unit)
+	}
+	lastChange := int64(0)
+	numerator := int64(0)
+	target := int64(duration) * int64(table.Division)
+	usPerQuarter := uint32(500000)
+	if len(table.Changes) != 0 {
+		if table.Changes[0].AbsTick < lastChange {
+			lastChange = table.Changes[0].AbsTick
+		}
+		for i := 0; i < len(table.Changes); i++ {
+			next := numerator + (table.Changes[i].AbsTick-lastChange)*int64(usPerQuarter)
+			if next*int64(time.Microsecond) > target {
+				break
+			}
+			numerator = next
+			lastChange = table.Changes[i].AbsTick
+			usPerQuarter = table.Changes[i].UsPerQuarter
+		}
+	}
+	if usPerQuarter == 0 {
+		return lastChange
+	}
+	return lastChange + divRound(target-numerator*int64(time.Microsecond), int64(usPerQuarter)*int64(time.Microsecond))
+}
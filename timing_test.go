@@ -0,0 +1,105 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTempoTableRoundTrip(t *testing.T) {
+	table := &TempoTable{
+		Division: 96,
+		Changes: []TempoChange{
+			{AbsTick: 0, UsPerQuarter: 500000},
+			{AbsTick: 192, UsPerQuarter: 250000},
+			{AbsTick: 384, UsPerQuarter: 1000000},
+			{AbsTick: 480, UsPerQuarter: 428571},
+		},
+	}
+	known := []struct {
+		absTick  int64
+		duration time.Duration
+	}{
+		{0, 0},
+		{96, 500 * time.Millisecond},
+		{192, time.Second},
+		{288, 1250 * time.Millisecond},
+		{384, 1500 * time.Millisecond},
+		{480, 2500 * time.Millisecond},
+		{576, 2500*time.Millisecond + 428571*time.Microsecond},
+	}
+	for _, test := range known {
+		if got := table.ConvertAbsTickToDuration(test.absTick); got != test.duration {
+			t.Errorf("tick %d: got %v, want %v", test.absTick, got, test.duration)
+		}
+		if got := table.ConvertDurationToAbsTick(test.duration); got != test.absTick {
+			t.Errorf("%v: got tick %d, want %d", test.duration, got, test.absTick)
+		}
+	}
+	for absTick := int64(0); absTick < 1000; absTick++ {
+		duration := table.ConvertAbsTickToDuration(absTick)
+		if got := table.ConvertDurationToAbsTick(duration); got != absTick {
+			t.Errorf("tick %d: converted to %v and back to tick %d", absTick, duration, got)
+		}
+	}
+	// Ticks 200 and 201 are at 1.020833s and 1.023437s, during the 250000 tempo
+	if got := table.ConvertDurationToAbsTick(1022 * time.Millisecond); got != 200 {
+		t.Errorf("1.022s: got tick %d, want the nearest tick 200", got)
+	}
+	if got := table.ConvertDurationToAbsTick(1023 * time.Millisecond); got != 201 {
+		t.Errorf("1.023s: got tick %d, want the nearest tick 201", got)
+	}
+}
+
+func TestTempoTableDropFrameRoundTrip(t *testing.T) {
+	// 29.97 frames per second, 80 ticks per frame
+	table := &TempoTable{Framerate: 29, Division: 80}
+	known := []struct {
+		absTick  int64
+		duration time.Duration
+	}{
+		{0, 0},
+		{80 * 30000, 1001 * time.Second},
+		{80 * 30000 * 36, 1001 * 36 * time.Second},
+		{80 * 30, 1001 * time.Millisecond},
+	}
+	for _, test := range known {
+		if got := table.ConvertAbsTickToDuration(test.absTick); got != test.duration {
+			t.Errorf("tick %d: got %v, want %v", test.absTick, got, test.duration)
+		}
+		if got := table.ConvertDurationToAbsTick(test.duration); got != test.absTick {
+			t.Errorf("%v: got tick %d, want %d", test.duration, got, test.absTick)
+		}
+	}
+	for _, base := range []int64{0, 80 * 30000 * 10, 80 * 30000 * 3600} {
+		for absTick := base; absTick < base+5000; absTick += 7 {
+			duration := table.ConvertAbsTickToDuration(absTick)
+			if got := table.ConvertDurationToAbsTick(duration); got != absTick {
+				t.Errorf("tick %d: converted to %v and back to tick %d", absTick, duration, got)
+			}
+		}
+	}
+}
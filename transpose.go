@@ -0,0 +1,108 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+)
+
+type TransposeOptions struct {
+	// Also transpose channel 10, which is reserved for percussion in General MIDI
+	IncludeDrumChannel bool
+	// Shift key signatures along with the notes
+	AdjustKeySignature bool
+}
+
+// Shifts every note by the given number of semitones.
+// Notes that would fall outside 0..127 are removed and reported as warnings.
+// Both ends of a note pair, and the key pressure in between, share the same
+// key, so they are always removed together.
+func (seq *Sequence) Transpose(semitones int, options TransposeOptions, warningCallback WarningCallback) {
+	for _, mtrk := range seq.Tracks {
+		mtrk.Transpose(semitones, options, warningCallback)
+	}
+}
+
+func (mtrk *MTrk) Transpose(semitones int, options TransposeOptions, warningCallback WarningCallback) {
+	// Returns false if the event has to be removed
+	transposeKey := func(event Event, key *Key, report bool) bool {
+		channel := event.Common().Channel
+		if *key >= 0x80 || (channel == 10 && !options.IncludeDrumChannel) {
+			return true
+		}
+		newKey := int(*key) + semitones
+		if newKey < 0 || newKey >= 0x80 {
+			if report {
+				warningCallback(newEditError(event, fmt.Errorf("can not transpose note %s at tick %d by %d semitones: out of range, removed", *key, event.Common().AbsTick, semitones)))
+			}
+			return false
+		}
+		*key = Key(newKey)
+		return true
+	}
+	mtrk.ConvertDeltaToAbsTick()
+	events := make([]Event, 0, len(mtrk.Events))
+	for _, event := range mtrk.Events {
+		keep := true
+		switch ev := event.(type) {
+		case *EventNoteOff:
+			keep = transposeKey(ev, &ev.Key, ev.RelatedNoteOn == nil)
+		case *EventNoteOn:
+			keep = transposeKey(ev, &ev.Key, true)
+		case *EventPolyphonicKeyPressure:
+			keep = transposeKey(ev, &ev.Key, len(ev.RelatedNoteOn) == 0)
+		case *MetaEventKeySignature:
+			if options.AdjustKeySignature {
+				ev.KeySignature = ev.KeySignature.Transpose(semitones)
+			}
+		}
+		if keep {
+			events = append(events, event)
+		}
+	}
+	if len(events) == len(mtrk.Events) {
+		return
+	}
+	mtrk.Events = events
+	err := mtrk.ConvertAbsToDeltaTick()
+	if err != nil {
+		warningCallback(newEditError(mtrk, err))
+	}
+}
+
+// Returns the key signature a number of semitones away, preferring the
+// spelling with fewer accidentals (F# over Gb for six accidentals).
+func (ks KeySignature) Transpose(semitones int) KeySignature {
+	if semitones%12 == 0 {
+		return ks
+	}
+	sf := int(int8(ks >> 8))
+	mi := uint8(ks)
+	sf = ((sf+7*semitones)%12 + 12) % 12
+	if sf > 6 {
+		sf -= 12
+	}
+	return KeySignature(int16(sf)<<8 | int16(mi))
+}
@@ -0,0 +1,69 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransposeOutOfRange(t *testing.T) {
+	data := buildTestSMF(buildTestMTrk(
+		0x00, 0x90, 0x7d, 0x64,
+		0x05, 0xa0, 0x7d, 0x30,
+		0x05, 0x90, 0x76, 0x64,
+		0x0a, 0x80, 0x7d, 0x40,
+		0x0a, 0x80, 0x76, 0x40,
+		0x00, 0xff, 0x2f, 0x00,
+	), testMTrk)
+	seq, err := DecodeSequenceFromSMF(bytes.NewReader(data), IgnoreWarnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []error
+	seq.Transpose(7, TransposeOptions{}, func(err error) {
+		warnings = append(warnings, err)
+	})
+	if len(warnings) != 1 {
+		t.Errorf("got warnings %v, want one for the removed note", warnings)
+	}
+	// Key 125 has no room, only key 118 is left, moved to 125
+	events := seq.Tracks[0].Events
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	noteOn, ok1 := events[0].(*EventNoteOn)
+	noteOff, ok2 := events[1].(*EventNoteOff)
+	if !ok1 || !ok2 || noteOn.Key != 125 || noteOff.Key != 125 {
+		t.Fatalf("got %+v and %+v, want a note of key 125", events[0], events[1])
+	}
+	if noteOn.AbsTick != 10 || noteOn.DeltaTick != 10 || noteOff.AbsTick != 30 || noteOff.DeltaTick != 20 {
+		t.Errorf("note moved to tick %d delta %d until tick %d delta %d", noteOn.AbsTick, noteOn.DeltaTick, noteOff.AbsTick, noteOff.DeltaTick)
+	}
+	seq.CalculateNotePair()
+	if noteOn.RelatedNoteOff != noteOff {
+		t.Errorf("note paired with %+v", noteOn.RelatedNoteOff)
+	}
+}
@@ -0,0 +1,709 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrUMPUnsupportedEvent = errors.New("midimark: event can not be represented as a Universal MIDI Packet")
+
+// UMP is a single Universal MIDI Packet of one to four 32-bit words
+type UMP []uint32
+
+// Message types, the upper 4 bits of a packet
+const (
+	UMPTypeUtility           uint8 = 0x0
+	UMPTypeSystem            uint8 = 0x1
+	UMPTypeMIDI1ChannelVoice uint8 = 0x2
+	UMPTypeData64            uint8 = 0x3
+	UMPTypeMIDI2ChannelVoice uint8 = 0x4
+	UMPTypeData128           uint8 = 0x5
+	UMPTypeFlexData          uint8 = 0xd
+	UMPTypeStream            uint8 = 0xf
+)
+
+// Status of Utility messages
+const (
+	UMPNoop               uint8 = 0x0
+	UMPJRClock            uint8 = 0x1
+	UMPJRTimestamp        uint8 = 0x2
+	UMPDeltaClockstampTPQ uint8 = 0x3
+	UMPDeltaClockstamp    uint8 = 0x4
+)
+
+// Status of SysEx7 and SysEx8 messages, and of Mixed Data Set messages
+const (
+	UMPSysExComplete       uint8 = 0x0
+	UMPSysExStart          uint8 = 0x1
+	UMPSysExContinue       uint8 = 0x2
+	UMPSysExEnd            uint8 = 0x3
+	UMPMixedDataSetHeader  uint8 = 0x8
+	UMPMixedDataSetPayload uint8 = 0x9
+)
+
+// Status of MIDI 2.0 Channel Voice messages
+const (
+	UMPRegisteredPerNoteController  uint8 = 0x0
+	UMPAssignablePerNoteController  uint8 = 0x1
+	UMPRegisteredController         uint8 = 0x2
+	UMPAssignableController         uint8 = 0x3
+	UMPRelativeRegisteredController uint8 = 0x4
+	UMPRelativeAssignableController uint8 = 0x5
+	UMPPerNotePitchBend             uint8 = 0x6
+	UMPNoteOff                      uint8 = 0x8
+	UMPNoteOn                       uint8 = 0x9
+	UMPPolyPressure                 uint8 = 0xa
+	UMPControlChange                uint8 = 0xb
+	UMPProgramChange                uint8 = 0xc
+	UMPChannelPressure              uint8 = 0xd
+	UMPPitchBend                    uint8 = 0xe
+	UMPPerNoteManagement            uint8 = 0xf
+)
+
+// Returns the number of 32-bit words in a packet of a message type
+func UMPWordCount(messageType uint8) int {
+	switch messageType & 0xf {
+	case 0x0, 0x1, 0x2, 0x6, 0x7:
+		return 1
+	case 0x3, 0x4, 0x8, 0x9, 0xa:
+		return 2
+	case 0xb, 0xc:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func (p UMP) MessageType() uint8 {
+	if len(p) == 0 {
+		return 0
+	}
+	return uint8(p[0] >> 28)
+}
+
+// Returns the group from 0 to 15, Utility and Stream messages have no group
+func (p UMP) Group() uint8 {
+	if len(p) == 0 {
+		return 0
+	}
+	return uint8(p[0]>>24) & 0xf
+}
+
+// Returns the packet in big endian byte order
+func (p UMP) EncodeBytes() []byte {
+	data := make([]byte, 4*len(p))
+	for i, word := range p {
+		binary.BigEndian.PutUint32(data[4*i:], word)
+	}
+	return data
+}
+
+func (p UMP) String() string {
+	words := make([]string, len(p))
+	for i, word := range p {
+		words[i] = fmt.Sprintf("%08x", word)
+	}
+	return strings.Join(words, " ")
+}
+
+// Reads a packet in big endian byte order
+func DecodeUMP(r io.Reader) (UMP, error) {
+	var buf [16]byte
+	_, err := io.ReadFull(r, buf[:4])
+	if err != nil {
+		return nil, err
+	}
+	p := make(UMP, UMPWordCount(buf[0]>>4))
+	_, err = io.ReadFull(r, buf[4:4*len(p)])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	for i := range p {
+		p[i] = binary.BigEndian.Uint32(buf[4*i:])
+	}
+	return p, nil
+}
+
+// Scales a value up to more bits, following the Min-Center-Max rules of the
+// MIDI 2.0 specification so that the center value stays in the center
+func UMPScaleUp(value uint32, srcBits, dstBits uint) uint32 {
+	scaleBits := dstBits - srcBits
+	shifted := value << scaleBits
+	if value <= 1<<(srcBits-1) {
+		return shifted
+	}
+	repeatBits := srcBits - 1
+	repeat := value & (1<<repeatBits - 1)
+	if scaleBits > repeatBits {
+		repeat <<= scaleBits - repeatBits
+	} else {
+		repeat >>= repeatBits - scaleBits
+	}
+	for repeat != 0 {
+		shifted |= repeat
+		repeat >>= repeatBits
+	}
+	return shifted
+}
+
+func UMPScaleDown(value uint32, srcBits, dstBits uint) uint32 {
+	return value >> (srcBits - dstBits)
+}
+
+// UMPMessage is the decoded form of a packet
+type UMPMessage interface {
+	UMP() UMP
+}
+
+// Message type 0, 32 bits
+type UMPUtility struct {
+	Status uint8
+	Data   uint32
+}
+
+// Message type 1, 32 bits, System Common and System Realtime messages
+type UMPSystem struct {
+	Group  uint8
+	Status uint8
+	Data1  uint8
+	Data2  uint8
+}
+
+// Message type 2, 32 bits.
+// Status is the upper 4 bits of the MIDI 1.0 status byte, Channel is 1-based
+// as in EventCommon.
+type UMPMIDI1ChannelVoice struct {
+	Group   uint8
+	Status  uint8
+	Channel uint8
+	Data1   uint8
+	Data2   uint8
+}
+
+// Message type 3, 64 bits, with up to 6 bytes of a System Exclusive message
+// excluding F0 and F7
+type UMPSysEx7 struct {
+	Group  uint8
+	Status uint8
+	Data   []byte
+}
+
+// Message type 4, 64 bits.
+// Index holds the 3rd and 4th bytes, such as the note number and attribute
+// type, or the bank and index of a controller. Channel is 1-based.
+type UMPMIDI2ChannelVoice struct {
+	Group   uint8
+	Status  uint8
+	Channel uint8
+	Index   uint16
+	Data    uint32
+}
+
+// Message type 5, 128 bits, with up to 13 bytes of a System Exclusive message
+type UMPSysEx8 struct {
+	Group    uint8
+	Status   uint8
+	StreamID uint8
+	Data     []byte
+}
+
+// Message type 5, 128 bits, a header or payload chunk of a Mixed Data Set
+type UMPMixedDataSet struct {
+	Group  uint8
+	Status uint8
+	MDSID  uint8
+	Data   [14]byte
+}
+
+// Message type D, 128 bits
+type UMPFlexData struct {
+	Group      uint8
+	Form       uint8
+	Address    uint8
+	Channel    uint8
+	StatusBank uint8
+	Status     uint8
+	Data       [3]uint32
+}
+
+// Message type F, 128 bits, Data holds the 16 bits after Status and the 3
+// following words
+type UMPStream struct {
+	Form   uint8
+	Status uint16
+	Data0  uint16
+	Data   [3]uint32
+}
+
+// Decodes a packet into one of the message types above.
+// Returns nil for reserved message types.
+func DecodeUMPMessage(p UMP) (UMPMessage, error) {
+	if len(p) == 0 || len(p) < UMPWordCount(p.MessageType()) {
+		return nil, fmt.Errorf("midimark: incomplete Universal MIDI Packet %s", p)
+	}
+	group, status := p.Group(), uint8(p[0]>>20)&0xf
+	switch p.MessageType() {
+	case UMPTypeUtility:
+		return &UMPUtility{Status: status, Data: p[0] & 0xfffff}, nil
+	case UMPTypeSystem:
+		return &UMPSystem{Group: group, Status: uint8(p[0] >> 16), Data1: uint8(p[0]>>8) & 0x7f, Data2: uint8(p[0]) & 0x7f}, nil
+	case UMPTypeMIDI1ChannelVoice:
+		return &UMPMIDI1ChannelVoice{Group: group, Status: status, Channel: uint8(p[0]>>16)&0xf + 1, Data1: uint8(p[0]>>8) & 0x7f, Data2: uint8(p[0]) & 0x7f}, nil
+	case UMPTypeData64:
+		count := int(p[0]>>16) & 0xf
+		if count > 6 {
+			return nil, fmt.Errorf("midimark: invalid byte count in Universal MIDI Packet %s", p)
+		}
+		data := append(UMP{p[0] & 0xffff}, p[1]).EncodeBytes()[2:]
+		return &UMPSysEx7{Group: group, Status: status, Data: append([]byte(nil), data[:count]...)}, nil
+	case UMPTypeMIDI2ChannelVoice:
+		return &UMPMIDI2ChannelVoice{Group: group, Status: status, Channel: uint8(p[0]>>16)&0xf + 1, Index: uint16(p[0]), Data: p[1]}, nil
+	case UMPTypeData128:
+		data := p.EncodeBytes()[2:]
+		if status == UMPMixedDataSetHeader || status == UMPMixedDataSetPayload {
+			msg := &UMPMixedDataSet{Group: group, Status: status, MDSID: uint8(p[0]>>16) & 0xf}
+			copy(msg.Data[:], data)
+			return msg, nil
+		}
+		count := int(p[0]>>16) & 0xf
+		if count < 1 || count > 14 {
+			return nil, fmt.Errorf("midimark: invalid byte count in Universal MIDI Packet %s", p)
+		}
+		return &UMPSysEx8{Group: group, Status: status, StreamID: data[0], Data: append([]byte(nil), data[1:count]...)}, nil
+	case UMPTypeFlexData:
+		return &UMPFlexData{
+			Group:      group,
+			Form:       uint8(p[0]>>22) & 0x3,
+			Address:    uint8(p[0]>>20) & 0x3,
+			Channel:    uint8(p[0]>>16)&0xf + 1,
+			StatusBank: uint8(p[0] >> 8),
+			Status:     uint8(p[0]),
+			Data:       [3]uint32{p[1], p[2], p[3]},
+		}, nil
+	case UMPTypeStream:
+		return &UMPStream{
+			Form:   uint8(p[0]>>26) & 0x3,
+			Status: uint16(p[0]>>16) & 0x3ff,
+			Data0:  uint16(p[0]),
+			Data:   [3]uint32{p[1], p[2], p[3]},
+		}, nil
+	}
+	return nil, nil
+}
+
+func (msg *UMPUtility) UMP() UMP {
+	return UMP{uint32(UMPTypeUtility)<<28 | uint32(msg.Status&0xf)<<20 | msg.Data&0xfffff}
+}
+
+func (msg *UMPSystem) UMP() UMP {
+	return UMP{uint32(UMPTypeSystem)<<28 | uint32(msg.Group&0xf)<<24 | uint32(msg.Status)<<16 | uint32(msg.Data1&0x7f)<<8 | uint32(msg.Data2&0x7f)}
+}
+
+func (msg *UMPMIDI1ChannelVoice) UMP() UMP {
+	return UMP{uint32(UMPTypeMIDI1ChannelVoice)<<28 | uint32(msg.Group&0xf)<<24 | uint32(msg.Status&0xf)<<20 | uint32((msg.Channel-1)&0xf)<<16 | uint32(msg.Data1&0x7f)<<8 | uint32(msg.Data2&0x7f)}
+}
+
+func (msg *UMPSysEx7) UMP() UMP {
+	var data [8]byte
+	count := copy(data[2:], msg.Data)
+	data[0] = UMPTypeData64<<4 | msg.Group&0xf
+	data[1] = (msg.Status&0xf)<<4 | uint8(count)
+	for i := 2; i < len(data); i++ {
+		data[i] &= 0x7f
+	}
+	return UMP{binary.BigEndian.Uint32(data[0:4]), binary.BigEndian.Uint32(data[4:8])}
+}
+
+func (msg *UMPMIDI2ChannelVoice) UMP() UMP {
+	return UMP{uint32(UMPTypeMIDI2ChannelVoice)<<28 | uint32(msg.Group&0xf)<<24 | uint32(msg.Status&0xf)<<20 | uint32((msg.Channel-1)&0xf)<<16 | uint32(msg.Index), msg.Data}
+}
+
+func (msg *UMPSysEx8) UMP() UMP {
+	var data [16]byte
+	count := copy(data[3:], msg.Data)
+	data[0] = UMPTypeData128<<4 | msg.Group&0xf
+	data[1] = (msg.Status&0xf)<<4 | uint8(count+1)
+	data[2] = msg.StreamID
+	return umpFromBytes(data[:])
+}
+
+func (msg *UMPMixedDataSet) UMP() UMP {
+	var data [16]byte
+	data[0] = UMPTypeData128<<4 | msg.Group&0xf
+	data[1] = (msg.Status&0xf)<<4 | msg.MDSID&0xf
+	copy(data[2:], msg.Data[:])
+	return umpFromBytes(data[:])
+}
+
+func (msg *UMPFlexData) UMP() UMP {
+	return UMP{
+		uint32(UMPTypeFlexData)<<28 | uint32(msg.Group&0xf)<<24 | uint32(msg.Form&0x3)<<22 | uint32(msg.Address&0x3)<<20 | uint32((msg.Channel-1)&0xf)<<16 | uint32(msg.StatusBank)<<8 | uint32(msg.Status),
+		msg.Data[0], msg.Data[1], msg.Data[2],
+	}
+}
+
+func (msg *UMPStream) UMP() UMP {
+	return UMP{
+		uint32(UMPTypeStream)<<28 | uint32(msg.Form&0x3)<<26 | uint32(msg.Status&0x3ff)<<16 | uint32(msg.Data0),
+		msg.Data[0], msg.Data[1], msg.Data[2],
+	}
+}
+
+func umpFromBytes(data []byte) UMP {
+	p := make(UMP, len(data)/4)
+	for i := range p {
+		p[i] = binary.BigEndian.Uint32(data[4*i:])
+	}
+	return p
+}
+
+// Converts a channel message, a System Common or System Realtime message, or a
+// System Exclusive message into MIDI 1.0 packets.
+// Meta events have no packet form and return ErrUMPUnsupportedEvent.
+func EventToUMP(ev Event, group uint8) ([]UMP, error) {
+	switch ev := ev.(type) {
+	case MetaEvent:
+		return nil, ErrUMPUnsupportedEvent
+	case *EventSystemExclusive:
+		return sysExToUMP(ev.Data, true, group), nil
+	case *EventEscape:
+		var packets []UMP
+		var err error
+		parser := NewRealtimeParser(func(event Event) {
+			if err != nil {
+				return
+			}
+			var eventPackets []UMP
+			eventPackets, err = EventToUMP(event, group)
+			packets = append(packets, eventPackets...)
+		}, IgnoreWarnings)
+		parser.Write(ev.Data)
+		if err == nil && len(packets) == 0 && len(ev.Data) != 0 {
+			// Part of a System Exclusive message split into several events
+			if ev.Data[0] == 0xf0 {
+				return sysExToUMP(ev.Data[1:], true, group), nil
+			}
+			return sysExToUMP(ev.Data, false, group), nil
+		}
+		return packets, err
+	}
+	msg, err := ev.EncodeRealtime()
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) == 0 || msg[0] < 0x80 || msg[0] == 0xf0 || msg[0] == 0xf7 {
+		return nil, ErrUMPUnsupportedEvent
+	}
+	var data [3]uint8
+	copy(data[:], msg)
+	if data[0] >= 0xf0 {
+		return []UMP{(&UMPSystem{Group: group, Status: data[0], Data1: data[1], Data2: data[2]}).UMP()}, nil
+	}
+	return []UMP{(&UMPMIDI1ChannelVoice{Group: group, Status: data[0] >> 4, Channel: data[0]&0xf + 1, Data1: data[1], Data2: data[2]}).UMP()}, nil
+}
+
+// Splits the bytes after F0 into SysEx7 packets, the message is incomplete
+// unless it ends with F7
+func sysExToUMP(data []byte, start bool, group uint8) []UMP {
+	complete := len(data) != 0 && data[len(data)-1] == 0xf7
+	if complete {
+		data = data[:len(data)-1]
+	}
+	var packets []UMP
+	for first := true; first || len(data) != 0; first = false {
+		chunk := data
+		if len(chunk) > 6 {
+			chunk = chunk[:6]
+		}
+		data = data[len(chunk):]
+		last := complete && len(data) == 0
+		status := UMPSysExContinue
+		switch {
+		case first && start && last:
+			status = UMPSysExComplete
+		case first && start:
+			status = UMPSysExStart
+		case last:
+			status = UMPSysExEnd
+		}
+		packets = append(packets, (&UMPSysEx7{Group: group, Status: status, Data: chunk}).UMP())
+	}
+	return packets
+}
+
+// UMPDecoder converts packets back into events, joining SysEx7 packets into
+// System Exclusive messages. MIDI 2.0 Channel Voice messages are translated
+// down to MIDI 1.0. Other message types produce no events.
+type UMPDecoder struct {
+	WarningCallback WarningCallback
+	translator      *UMPTranslator
+	sysex           [16][]byte
+	inSysEx         [16]bool
+}
+
+func NewUMPDecoder(warningCallback WarningCallback) *UMPDecoder {
+	return &UMPDecoder{
+		WarningCallback: warningCallback,
+		translator:      NewUMPTranslator(),
+	}
+}
+
+func (d *UMPDecoder) Reset() {
+	d.translator.Reset()
+	for i := range d.sysex {
+		d.sysex[i] = nil
+		d.inSysEx[i] = false
+	}
+}
+
+func (d *UMPDecoder) Decode(p UMP) ([]Event, error) {
+	msg, err := DecodeUMPMessage(p)
+	if err != nil {
+		return nil, err
+	}
+	switch msg := msg.(type) {
+	case *UMPSystem:
+		return d.decodeBytes([]byte{msg.Status, msg.Data1, msg.Data2})
+	case *UMPMIDI1ChannelVoice:
+		return d.decodeBytes([]byte{msg.Status<<4 | (msg.Channel-1)&0xf, msg.Data1, msg.Data2})
+	case *UMPMIDI2ChannelVoice:
+		var events []Event
+		for _, packet := range d.translator.MIDI2ToMIDI1(p) {
+			packetEvents, err := d.Decode(packet)
+			if err != nil {
+				return events, err
+			}
+			events = append(events, packetEvents...)
+		}
+		return events, nil
+	case *UMPSysEx7:
+		group := msg.Group
+		switch msg.Status {
+		case UMPSysExComplete, UMPSysExStart:
+			if d.inSysEx[group] {
+				d.WarningCallback(fmt.Errorf("midimark: system exclusive message in group %d interrupted", group))
+			}
+			d.sysex[group] = append(d.sysex[group][:0], msg.Data...)
+			d.inSysEx[group] = msg.Status == UMPSysExStart
+		case UMPSysExContinue, UMPSysExEnd:
+			if !d.inSysEx[group] {
+				d.WarningCallback(fmt.Errorf("midimark: unexpected system exclusive continuation in group %d", group))
+				return nil, nil
+			}
+			d.sysex[group] = append(d.sysex[group], msg.Data...)
+			d.inSysEx[group] = msg.Status == UMPSysExContinue
+		default:
+			return nil, fmt.Errorf("midimark: invalid SysEx7 status in Universal MIDI Packet %s", p)
+		}
+		if d.inSysEx[group] {
+			return nil, nil
+		}
+		data := append(append([]byte(nil), d.sysex[group]...), 0xf7)
+		d.sysex[group] = d.sysex[group][:0]
+		return []Event{&EventSystemExclusive{Data: data}}, nil
+	}
+	return nil, nil
+}
+
+func (d *UMPDecoder) decodeBytes(msg []byte) ([]Event, error) {
+	switch {
+	case msg[0]&0xf0 == 0xc0 || msg[0]&0xf0 == 0xd0 || msg[0] == 0xf1 || msg[0] == 0xf3:
+		msg = msg[:2]
+	case msg[0] >= 0xf4:
+		msg = msg[:1]
+	}
+	status := uint8(0)
+	event, err := DecodeEventFromRealtime(bytes.NewReader(msg), &status, d.WarningCallback)
+	if err != nil {
+		return nil, err
+	}
+	return []Event{event}, nil
+}
+
+// UMPTranslator converts between MIDI 1.0 and MIDI 2.0 Channel Voice packets
+// following the default translation of the MIDI 2.0 specification.
+// Bank Select and RPN/NRPN Control Changes are collected into MIDI 2.0 Program
+// Change and Registered/Assignable Controller messages, so the translator
+// keeps state for each group and channel.
+type UMPTranslator struct {
+	groups [16]umpTranslatorGroup
+}
+
+type umpTranslatorGroup struct {
+	controllers *ControllerDecoder
+	bank        [16][2]uint8
+	hasBank     [16]bool
+}
+
+func NewUMPTranslator() *UMPTranslator {
+	t := &UMPTranslator{}
+	t.Reset()
+	return t
+}
+
+func (t *UMPTranslator) Reset() {
+	for i := range t.groups {
+		t.groups[i] = umpTranslatorGroup{
+			controllers: NewControllerDecoder(),
+		}
+	}
+}
+
+// Translates a MIDI 1.0 Channel Voice packet, other packets are returned as is.
+// Returns no packets for Control Changes that only select a bank or parameter.
+func (t *UMPTranslator) MIDI1ToMIDI2(p UMP) []UMP {
+	msg, err := DecodeUMPMessage(p)
+	if err != nil {
+		return nil
+	}
+	in, ok := msg.(*UMPMIDI1ChannelVoice)
+	if !ok {
+		return []UMP{p}
+	}
+	state := &t.groups[in.Group&0xf]
+	ch := (in.Channel - 1) & 0xf
+	out := &UMPMIDI2ChannelVoice{
+		Group:   in.Group,
+		Status:  in.Status,
+		Channel: in.Channel,
+	}
+	switch in.Status {
+	case 0x8, 0x9:
+		out.Index = uint16(in.Data1) << 8
+		if in.Status == 0x9 && in.Data2 == 0 {
+			// Note On with velocity 0 is a Note Off with the default velocity
+			out.Status = UMPNoteOff
+			out.Data = UMPScaleUp(64, 7, 16) << 16
+		} else {
+			out.Data = UMPScaleUp(uint32(in.Data2), 7, 16) << 16
+		}
+	case 0xa:
+		out.Index = uint16(in.Data1) << 8
+		out.Data = UMPScaleUp(uint32(in.Data2), 7, 32)
+	case 0xb:
+		switch in.Data1 {
+		case 0, 32:
+			state.bank[ch][in.Data1/32] = in.Data2
+			state.hasBank[ch] = true
+			return nil
+		case 6, 38, 96, 97, 98, 99, 100, 101:
+			change, ok := state.controllers.Decode(&EventControlChange{
+				EventCommon: EventCommon{Channel: in.Channel},
+				Control:     in.Data1,
+				Value:       in.Data2,
+			})
+			if !ok || change.Kind == Controller14Bit {
+				return nil
+			}
+			out.Status = UMPRegisteredController
+			if change.Kind == ControllerNRPN {
+				out.Status = UMPAssignableController
+			}
+			out.Index = (change.Number>>7)<<8 | change.Number&0x7f
+			out.Data = UMPScaleUp(uint32(change.Value), 14, 32)
+		default:
+			out.Index = uint16(in.Data1) << 8
+			out.Data = UMPScaleUp(uint32(in.Data2), 7, 32)
+		}
+	case 0xc:
+		if state.hasBank[ch] {
+			out.Index = 0x01
+		}
+		out.Data = uint32(in.Data1)<<24 | uint32(state.bank[ch][0])<<8 | uint32(state.bank[ch][1])
+	case 0xd:
+		out.Data = UMPScaleUp(uint32(in.Data1), 7, 32)
+	case 0xe:
+		out.Data = UMPScaleUp(uint32(in.Data2)<<7|uint32(in.Data1), 14, 32)
+	default:
+		return nil
+	}
+	return []UMP{out.UMP()}
+}
+
+// Translates a MIDI 2.0 Channel Voice packet, other packets are returned as is.
+// Per-note controllers, per-note pitch bend, per-note management and relative
+// controllers have no MIDI 1.0 form and return no packets.
+func (t *UMPTranslator) MIDI2ToMIDI1(p UMP) []UMP {
+	msg, err := DecodeUMPMessage(p)
+	if err != nil {
+		return nil
+	}
+	in, ok := msg.(*UMPMIDI2ChannelVoice)
+	if !ok {
+		return []UMP{p}
+	}
+	packet := func(status, data1, data2 uint8) UMP {
+		return (&UMPMIDI1ChannelVoice{Group: in.Group, Status: status, Channel: in.Channel, Data1: data1, Data2: data2}).UMP()
+	}
+	note := uint8(in.Index>>8) & 0x7f
+	switch in.Status {
+	case UMPNoteOff:
+		return []UMP{packet(0x8, note, uint8(UMPScaleDown(in.Data>>16, 16, 7)))}
+	case UMPNoteOn:
+		velocity := uint8(UMPScaleDown(in.Data>>16, 16, 7))
+		if velocity == 0 {
+			// Velocity 0 would turn the note off
+			velocity = 1
+		}
+		return []UMP{packet(0x9, note, velocity)}
+	case UMPPolyPressure:
+		return []UMP{packet(0xa, note, uint8(UMPScaleDown(in.Data, 32, 7)))}
+	case UMPControlChange:
+		return []UMP{packet(0xb, note, uint8(UMPScaleDown(in.Data, 32, 7)))}
+	case UMPRegisteredController, UMPAssignableController:
+		value := UMPScaleDown(in.Data, 32, 14)
+		msb, lsb := uint8(101), uint8(100)
+		if in.Status == UMPAssignableController {
+			msb, lsb = 99, 98
+		}
+		return []UMP{
+			packet(0xb, msb, uint8(in.Index>>8)&0x7f),
+			packet(0xb, lsb, uint8(in.Index)&0x7f),
+			packet(0xb, 6, uint8(value>>7)&0x7f),
+			packet(0xb, 38, uint8(value)&0x7f),
+		}
+	case UMPProgramChange:
+		var packets []UMP
+		if in.Index&0x01 != 0 {
+			packets = append(packets, packet(0xb, 0, uint8(in.Data>>8)&0x7f), packet(0xb, 32, uint8(in.Data)&0x7f))
+		}
+		return append(packets, packet(0xc, uint8(in.Data>>24)&0x7f, 0))
+	case UMPChannelPressure:
+		return []UMP{packet(0xd, uint8(UMPScaleDown(in.Data, 32, 7)), 0)}
+	case UMPPitchBend:
+		value := UMPScaleDown(in.Data, 32, 14)
+		return []UMP{packet(0xe, uint8(value)&0x7f, uint8(value>>7)&0x7f)}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUMPScaleUp(t *testing.T) {
+	// Worked examples of the Min-Center-Max scaling in the MIDI 2.0 specification
+	tests := []struct {
+		value            uint32
+		srcBits, dstBits uint
+		want             uint32
+	}{
+		{0x00, 7, 16, 0x0000},
+		{0x01, 7, 16, 0x0200},
+		{0x40, 7, 16, 0x8000},
+		{0x41, 7, 16, 0x8208},
+		{0x64, 7, 16, 0xc924},
+		{0x7f, 7, 16, 0xffff},
+		{0x00, 7, 32, 0x00000000},
+		{0x40, 7, 32, 0x80000000},
+		{0x41, 7, 32, 0x82082082},
+		{0x7f, 7, 32, 0xffffffff},
+		{0x0000, 14, 32, 0x00000000},
+		{0x2000, 14, 32, 0x80000000},
+		{0x2001, 14, 32, 0x80040020},
+		{0x3fff, 14, 32, 0xffffffff},
+	}
+	for _, test := range tests {
+		if got := UMPScaleUp(test.value, test.srcBits, test.dstBits); got != test.want {
+			t.Errorf("UMPScaleUp(%#x, %d, %d) = %#x, want %#x", test.value, test.srcBits, test.dstBits, got, test.want)
+		}
+	}
+}
+
+func TestUMPScaleDown(t *testing.T) {
+	tests := []struct {
+		value            uint32
+		srcBits, dstBits uint
+		want             uint32
+	}{
+		{0x0000, 16, 7, 0x00},
+		{0x8000, 16, 7, 0x40},
+		{0xffff, 16, 7, 0x7f},
+		{0x80000000, 32, 7, 0x40},
+		{0xffffffff, 32, 7, 0x7f},
+		{0x80000000, 32, 14, 0x2000},
+		{0xffffffff, 32, 14, 0x3fff},
+	}
+	for _, test := range tests {
+		if got := UMPScaleDown(test.value, test.srcBits, test.dstBits); got != test.want {
+			t.Errorf("UMPScaleDown(%#x, %d, %d) = %#x, want %#x", test.value, test.srcBits, test.dstBits, got, test.want)
+		}
+	}
+	// Scaling up and back down must give the original value
+	for _, bits := range []struct{ src, dst uint }{{7, 16}, {7, 32}, {14, 32}} {
+		for value := uint32(0); value < 1<<bits.src; value++ {
+			if got := UMPScaleDown(UMPScaleUp(value, bits.src, bits.dst), bits.dst, bits.src); got != value {
+				t.Errorf("%d bits to %d bits: %#x scales back to %#x", bits.src, bits.dst, value, got)
+			}
+		}
+	}
+}
+
+func TestUMPMessageRoundTrip(t *testing.T) {
+	var mds [14]byte
+	for i := range mds {
+		mds[i] = byte(i + 1)
+	}
+	tests := []struct {
+		msg    UMPMessage
+		packet UMP
+	}{
+		{&UMPUtility{Status: UMPDeltaClockstamp, Data: 0x12345}, UMP{0x00412345}},
+		{&UMPSystem{Group: 1, Status: 0xf2, Data1: 0x10, Data2: 0x00}, UMP{0x11f21000}},
+		{&UMPMIDI1ChannelVoice{Group: 2, Status: 0x9, Channel: 3, Data1: 60, Data2: 100}, UMP{0x22923c64}},
+		{&UMPSysEx7{Group: 0, Status: UMPSysExComplete, Data: []byte{0x7e, 0x7f, 0x09, 0x01}}, UMP{0x30047e7f, 0x09010000}},
+		{&UMPMIDI2ChannelVoice{Group: 0, Status: UMPNoteOn, Channel: 1, Index: 0x3c00, Data: 0xc9240000}, UMP{0x40903c00, 0xc9240000}},
+		{&UMPSysEx8{Group: 0, Status: UMPSysExComplete, StreamID: 5, Data: []byte{1, 2, 3}}, UMP{0x50040501, 0x02030000, 0, 0}},
+		{&UMPMixedDataSet{Group: 0, Status: UMPMixedDataSetHeader, MDSID: 2, Data: mds}, UMP{0x50820102, 0x03040506, 0x0708090a, 0x0b0c0d0e}},
+		{&UMPFlexData{Group: 0, Form: 0, Address: 1, Channel: 1, StatusBank: 0, Status: 0, Data: [3]uint32{50000000, 0, 0}}, UMP{0xd0100000, 0x02faf080, 0, 0}},
+		{&UMPStream{Form: 0, Status: UMPStartOfClip}, UMP{0xf0200000, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		packet := test.msg.UMP()
+		if packet.String() != test.packet.String() {
+			t.Errorf("%T: encoded %s, want %s", test.msg, packet, test.packet)
+			continue
+		}
+		decoded, err := DecodeUMP(bytes.NewReader(packet.EncodeBytes()))
+		if err != nil || decoded.String() != packet.String() {
+			t.Errorf("%T: bytes of %s decoded to %s, %v", test.msg, packet, decoded, err)
+		}
+		msg, err := DecodeUMPMessage(packet)
+		if err != nil || !reflect.DeepEqual(msg, test.msg) {
+			t.Errorf("%s: decoded %+v, want %+v", packet, msg, test.msg)
+		}
+	}
+}
+
+func TestUMPTranslator(t *testing.T) {
+	tests := []struct {
+		midi1 []UMP
+		midi2 []UMP
+	}{
+		// Note On
+		{[]UMP{{0x20903c64}}, []UMP{{0x40903c00, 0xc9240000}}},
+		// Pitch bend center
+		{[]UMP{{0x20e00040}}, []UMP{{0x40e00000, 0x80000000}}},
+		// Control Change
+		{[]UMP{{0x20b0077f}}, []UMP{{0x40b00700, 0xffffffff}}},
+		// Bank Select is swallowed and sent with the Program Change
+		{[]UMP{{0x20b00001}, {0x20b02002}, {0x20c00500}}, []UMP{{0x40c00001, 0x05000102}}},
+		// RPN is sent as a Registered Controller
+		{[]UMP{{0x20b06500}, {0x20b06400}, {0x20b0060c}, {0x20b02600}}, []UMP{{0x40200000, 0x18000000}}},
+	}
+	for _, test := range tests {
+		translator := NewUMPTranslator()
+		var midi2 []UMP
+		for _, p := range test.midi1 {
+			midi2 = append(midi2, translator.MIDI1ToMIDI2(p)...)
+		}
+		// Data Entry LSB repeats the Registered Controller
+		if len(midi2) > len(test.midi2) {
+			midi2 = midi2[len(midi2)-len(test.midi2):]
+		}
+		if !reflect.DeepEqual(midi2, test.midi2) {
+			t.Errorf("%v translated to %v, want %v", test.midi1, midi2, test.midi2)
+		}
+		var midi1 []UMP
+		for _, p := range test.midi2 {
+			midi1 = append(midi1, translator.MIDI2ToMIDI1(p)...)
+		}
+		if !reflect.DeepEqual(midi1, test.midi1) {
+			t.Errorf("%v translated to %v, want %v", test.midi2, midi1, test.midi1)
+		}
+	}
+
+	// Note On with velocity 0 is a Note Off
+	if got := NewUMPTranslator().MIDI1ToMIDI2(UMP{0x20903c00}); !reflect.DeepEqual(got, []UMP{{0x40803c00, 0x80000000}}) {
+		t.Errorf("Note On with velocity 0 translated to %v", got)
+	}
+}
+
+func TestUMPSysExRoundTrip(t *testing.T) {
+	ev := &EventSystemExclusive{Data: []byte{0x41, 0x10, 0x42, 0x12, 0x40, 0x00, 0x7f, 0x00, 0x41, 0xf7}}
+	packets, err := EventToUMP(ev, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UMP{{0x33164110, 0x42124000}, {0x33337f00, 0x41000000}}
+	if !reflect.DeepEqual(packets, want) {
+		t.Errorf("encoded %v, want %v", packets, want)
+	}
+	decoder := NewUMPDecoder(IgnoreWarnings)
+	var events []Event
+	for _, p := range packets {
+		decoded, err := decoder.Decode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, decoded...)
+	}
+	if len(events) != 1 || !bytes.Equal(events[0].(*EventSystemExclusive).Data, ev.Data) {
+		t.Errorf("decoded %v, want % x", events, ev.Data)
+	}
+}
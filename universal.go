@@ -0,0 +1,394 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+// ManufacturerID holds a one byte ID in the upper byte, as in 0x410000 for
+// Roland, or a three byte ID starting with 00, as in 0x002029 for Novation
+type ManufacturerID uint32
+
+var manufacturerNames = map[ManufacturerID]string{
+	0x010000: "Sequential Circuits",
+	0x040000: "Moog",
+	0x060000: "Lexicon",
+	0x070000: "Kurzweil",
+	0x080000: "Fender",
+	0x0f0000: "Ensoniq",
+	0x100000: "Oberheim",
+	0x110000: "Apple",
+	0x180000: "E-mu",
+	0x1a0000: "ART",
+	0x1c0000: "Eventide",
+	0x240000: "Hohner",
+	0x330000: "Clavia",
+	0x3e0000: "Waldorf",
+	0x400000: "Kawai",
+	0x410000: "Roland",
+	0x420000: "Korg",
+	0x430000: "Yamaha",
+	0x440000: "Casio",
+	0x470000: "Akai",
+	0x4c0000: "Sony",
+	0x520000: "Zoom",
+	0x7d0000: "Non-Commercial",
+	0x7e0000: "Universal Non-Realtime",
+	0x7f0000: "Universal Realtime",
+	0x00000e: "Alesis",
+	0x00003b: "MOTU",
+	0x000041: "Microsoft",
+	0x000066: "Mackie",
+	0x000105: "M-Audio",
+	0x00201f: "TC Electronic",
+	0x002029: "Novation",
+	0x002032: "Behringer",
+	0x002033: "Access",
+	0x00203c: "Elektron",
+	0x00206b: "Arturia",
+	0x002109: "Native Instruments",
+}
+
+// Reads the manufacturer ID at the start of data, returns the number of bytes used
+func decodeManufacturerID(data []byte) (ManufacturerID, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	if data[0] != 0x00 {
+		return ManufacturerID(data[0]&0x7f) << 16, 1
+	}
+	if len(data) < 3 {
+		return 0, 0
+	}
+	return ManufacturerID(data[1]&0x7f)<<8 | ManufacturerID(data[2]&0x7f), 3
+}
+
+func (id ManufacturerID) Bytes() []byte {
+	if id>>16 != 0 {
+		return []byte{uint8(id>>16) & 0x7f}
+	}
+	return []byte{0x00, uint8(id>>8) & 0x7f, uint8(id) & 0x7f}
+}
+
+func (id ManufacturerID) String() string {
+	if name, ok := manufacturerNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("% x", id.Bytes())
+}
+
+func ParseManufacturerID(str string) (ManufacturerID, error) {
+	for id, name := range manufacturerNames {
+		if name == str {
+			return id, nil
+		}
+	}
+	data, err := parseHexDump(str)
+	if id, n := decodeManufacturerID(data); err == nil && n != 0 && n == len(data) {
+		return id, nil
+	}
+	return 0, fmt.Errorf("unrecognized manufacturer ID %q", str)
+}
+
+type HandshakeType uint8
+
+const (
+	HandshakeEOF    HandshakeType = 0x7b
+	HandshakeWait   HandshakeType = 0x7c
+	HandshakeCancel HandshakeType = 0x7d
+	HandshakeNAK    HandshakeType = 0x7e
+	HandshakeACK    HandshakeType = 0x7f
+)
+
+var handshakeTypeToString = map[HandshakeType]string{
+	HandshakeEOF:    "eof",
+	HandshakeWait:   "wait",
+	HandshakeCancel: "cancel",
+	HandshakeNAK:    "nak",
+	HandshakeACK:    "ack",
+}
+
+// Universal Non-Realtime 7E dev 06 01
+type SysExIdentityRequest struct {
+	DeviceID uint8
+}
+
+// Universal Non-Realtime 7E dev 06 02
+type SysExIdentityReply struct {
+	DeviceID     uint8
+	Manufacturer ManufacturerID
+	Family       uint16
+	Model        uint16
+	Version      [4]uint8
+}
+
+// Universal Non-Realtime 7E dev 01.
+// Period is in nanoseconds, lengths and loop points are in words.
+// LoopType is 0 for forward, 1 for alternating, 0x7f for loop off.
+type SysExSampleDumpHeader struct {
+	DeviceID         uint8
+	Sample           uint16
+	BitsPerSample    uint8
+	Period           uint32
+	Length           uint32
+	SustainLoopStart uint32
+	SustainLoopEnd   uint32
+	LoopType         uint8
+}
+
+// Universal Non-Realtime 7E dev 7B..7F, used by sample dump and file dump
+type SysExHandshake struct {
+	DeviceID uint8
+	Type     HandshakeType
+	Packet   uint8
+}
+
+// Decodes the Universal Non-Realtime message in body, which excludes F7
+func decodeUniversalNonRealtime(body []byte) (SysEx, error) {
+	switch body[2] {
+	case 0x06:
+		if len(body) == 4 && body[3] == 0x01 {
+			return &SysExIdentityRequest{DeviceID: body[1]}, nil
+		}
+		if len(body) < 4 || body[3] != 0x02 {
+			return nil, nil
+		}
+		manufacturer, n := decodeManufacturerID(body[4:])
+		if n == 0 || len(body) != 4+n+8 {
+			return nil, nil
+		}
+		data := body[4+n:]
+		return &SysExIdentityReply{
+			DeviceID:     body[1],
+			Manufacturer: manufacturer,
+			Family:       uint16(data[1]&0x7f)<<7 | uint16(data[0]&0x7f),
+			Model:        uint16(data[3]&0x7f)<<7 | uint16(data[2]&0x7f),
+			Version:      [4]uint8{data[4], data[5], data[6], data[7]},
+		}, nil
+	case 0x01:
+		if len(body) != 19 {
+			return nil, nil
+		}
+		return &SysExSampleDumpHeader{
+			DeviceID:         body[1],
+			Sample:           uint16(body[4]&0x7f)<<7 | uint16(body[3]&0x7f),
+			BitsPerSample:    body[5],
+			Period:           decodeUniversal21Bit(body[6:9]),
+			Length:           decodeUniversal21Bit(body[9:12]),
+			SustainLoopStart: decodeUniversal21Bit(body[12:15]),
+			SustainLoopEnd:   decodeUniversal21Bit(body[15:18]),
+			LoopType:         body[18],
+		}, nil
+	case 0x7b, 0x7c, 0x7d, 0x7e, 0x7f:
+		if len(body) != 4 {
+			return nil, nil
+		}
+		return &SysExHandshake{
+			DeviceID: body[1],
+			Type:     HandshakeType(body[2]),
+			Packet:   body[3],
+		}, nil
+	}
+	return nil, nil
+}
+
+// Three 7-bit bytes, LSB first
+func decodeUniversal21Bit(data []byte) uint32 {
+	return uint32(data[2]&0x7f)<<14 | uint32(data[1]&0x7f)<<7 | uint32(data[0]&0x7f)
+}
+
+func encodeUniversal21Bit(value uint32) []byte {
+	return []byte{uint8(value) & 0x7f, uint8(value>>7) & 0x7f, uint8(value>>14) & 0x7f}
+}
+
+func decodeUniversalFromXML(el *etree.Element) (SysEx, error) {
+	attr := func(name string, bitSize int) (uint64, error) {
+		value, err := strconv.ParseUint(el.SelectAttrValue(name, ""), 0, bitSize)
+		if err != nil {
+			return 0, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+		}
+		return value, nil
+	}
+	invalid := func(name string) error {
+		return newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+	}
+	deviceID, err := attr("device", 7)
+	if err != nil {
+		return nil, err
+	}
+	switch el.Tag {
+	case "IdentityRequest":
+		return &SysExIdentityRequest{DeviceID: uint8(deviceID)}, nil
+	case "IdentityReply":
+		manufacturer, err := ParseManufacturerID(el.SelectAttrValue("manufacturer", ""))
+		if err != nil {
+			return nil, invalid("manufacturer")
+		}
+		family, err := attr("family", 14)
+		if err != nil {
+			return nil, err
+		}
+		model, err := attr("model", 14)
+		if err != nil {
+			return nil, err
+		}
+		version, err := parseHexDump(el.SelectAttrValue("version", ""))
+		if err != nil || len(version) != 4 {
+			return nil, invalid("version")
+		}
+		return &SysExIdentityReply{
+			DeviceID:     uint8(deviceID),
+			Manufacturer: manufacturer,
+			Family:       uint16(family),
+			Model:        uint16(model),
+			Version:      [4]uint8{version[0], version[1], version[2], version[3]},
+		}, nil
+	case "SampleDumpHeader":
+		var values [7]uint64
+		for i, name := range []string{"sample", "bits", "period", "length", "loop-start", "loop-end", "loop-type"} {
+			bitSize := 21
+			switch i {
+			case 0:
+				bitSize = 14
+			case 1, 6:
+				bitSize = 7
+			}
+			values[i], err = attr(name, bitSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &SysExSampleDumpHeader{
+			DeviceID:         uint8(deviceID),
+			Sample:           uint16(values[0]),
+			BitsPerSample:    uint8(values[1]),
+			Period:           uint32(values[2]),
+			Length:           uint32(values[3]),
+			SustainLoopStart: uint32(values[4]),
+			SustainLoopEnd:   uint32(values[5]),
+			LoopType:         uint8(values[6]),
+		}, nil
+	case "Handshake":
+		handshake := &SysExHandshake{
+			DeviceID: uint8(deviceID),
+		}
+		typeStr := el.SelectAttrValue("type", "")
+		for handshakeType, str := range handshakeTypeToString {
+			if str == typeStr {
+				handshake.Type = handshakeType
+			}
+		}
+		if handshake.Type == 0 {
+			return nil, invalid("type")
+		}
+		packet, err := attr("packet", 7)
+		if err != nil {
+			return nil, err
+		}
+		handshake.Packet = uint8(packet)
+		return handshake, nil
+	}
+	return nil, nil
+}
+
+func (sysex *SysExIdentityRequest) SysExData() []byte {
+	return []byte{0x7e, sysex.DeviceID & 0x7f, 0x06, 0x01, 0xf7}
+}
+
+func (sysex *SysExIdentityRequest) sysexTag() string {
+	return "IdentityRequest"
+}
+
+func (sysex *SysExIdentityRequest) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+}
+
+func (sysex *SysExIdentityReply) SysExData() []byte {
+	data := make([]byte, 0, 16)
+	data = append(data, 0x7e, sysex.DeviceID&0x7f, 0x06, 0x02)
+	data = append(data, sysex.Manufacturer.Bytes()...)
+	data = append(data, uint8(sysex.Family)&0x7f, uint8(sysex.Family>>7)&0x7f, uint8(sysex.Model)&0x7f, uint8(sysex.Model>>7)&0x7f)
+	for _, b := range sysex.Version {
+		data = append(data, b&0x7f)
+	}
+	data = append(data, 0xf7)
+	return data
+}
+
+func (sysex *SysExIdentityReply) sysexTag() string {
+	return "IdentityReply"
+}
+
+func (sysex *SysExIdentityReply) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("manufacturer", sysex.Manufacturer.String())
+	el.CreateAttr("family", fmt.Sprintf("%#04x", sysex.Family))
+	el.CreateAttr("model", fmt.Sprintf("%#04x", sysex.Model))
+	el.CreateAttr("version", fmt.Sprintf("% x", sysex.Version[:]))
+}
+
+func (sysex *SysExSampleDumpHeader) SysExData() []byte {
+	data := make([]byte, 0, 20)
+	data = append(data, 0x7e, sysex.DeviceID&0x7f, 0x01, uint8(sysex.Sample)&0x7f, uint8(sysex.Sample>>7)&0x7f, sysex.BitsPerSample&0x7f)
+	data = append(data, encodeUniversal21Bit(sysex.Period)...)
+	data = append(data, encodeUniversal21Bit(sysex.Length)...)
+	data = append(data, encodeUniversal21Bit(sysex.SustainLoopStart)...)
+	data = append(data, encodeUniversal21Bit(sysex.SustainLoopEnd)...)
+	data = append(data, sysex.LoopType&0x7f, 0xf7)
+	return data
+}
+
+func (sysex *SysExSampleDumpHeader) sysexTag() string {
+	return "SampleDumpHeader"
+}
+
+func (sysex *SysExSampleDumpHeader) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("sample", fmt.Sprintf("%d", sysex.Sample))
+	el.CreateAttr("bits", fmt.Sprintf("%d", sysex.BitsPerSample))
+	el.CreateAttr("period", fmt.Sprintf("%d", sysex.Period))
+	el.CreateAttr("length", fmt.Sprintf("%d", sysex.Length))
+	el.CreateAttr("loop-start", fmt.Sprintf("%d", sysex.SustainLoopStart))
+	el.CreateAttr("loop-end", fmt.Sprintf("%d", sysex.SustainLoopEnd))
+	el.CreateAttr("loop-type", fmt.Sprintf("%#02x", sysex.LoopType))
+}
+
+func (sysex *SysExHandshake) SysExData() []byte {
+	return []byte{0x7e, sysex.DeviceID & 0x7f, uint8(sysex.Type) & 0x7f, sysex.Packet & 0x7f, 0xf7}
+}
+
+func (sysex *SysExHandshake) sysexTag() string {
+	return "Handshake"
+}
+
+func (sysex *SysExHandshake) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("device", fmt.Sprintf("%#02x", sysex.DeviceID))
+	el.CreateAttr("type", handshakeTypeToString[sysex.Type])
+	el.CreateAttr("packet", fmt.Sprintf("%d", sysex.Packet))
+}
@@ -0,0 +1,55 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import "testing"
+
+func TestUniversalSysExRoundTrip(t *testing.T) {
+	tests := []struct {
+		sysex SysEx
+		data  []byte
+	}{
+		{&SysExIdentityRequest{DeviceID: SysExAllCall}, []byte{0x7e, 0x7f, 0x06, 0x01, 0xf7}},
+		{
+			&SysExIdentityReply{DeviceID: 0x10, Manufacturer: 0x430000, Family: 0x0141, Model: 0x1234, Version: [4]uint8{1, 2, 3, 4}},
+			[]byte{0x7e, 0x10, 0x06, 0x02, 0x43, 0x41, 0x02, 0x34, 0x24, 0x01, 0x02, 0x03, 0x04, 0xf7},
+		},
+		{
+			&SysExIdentityReply{DeviceID: SysExAllCall, Manufacturer: 0x002029, Family: 0x3fff, Model: 0, Version: [4]uint8{0, 0, 1, 0x7f}},
+			[]byte{0x7e, 0x7f, 0x06, 0x02, 0x00, 0x20, 0x29, 0x7f, 0x7f, 0x00, 0x00, 0x00, 0x00, 0x01, 0x7f, 0xf7},
+		},
+		{
+			// 16-bit samples at 44.1 kHz, looping forward over the whole sample
+			&SysExSampleDumpHeader{DeviceID: 0, Sample: 0x0201, BitsPerSample: 16, Period: 22676, Length: 100000, SustainLoopStart: 0, SustainLoopEnd: 99999, LoopType: 0},
+			[]byte{0x7e, 0x00, 0x01, 0x01, 0x04, 0x10, 0x14, 0x31, 0x01, 0x20, 0x0d, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x0d, 0x06, 0x00, 0xf7},
+		},
+		{&SysExHandshake{DeviceID: 0x05, Type: HandshakeACK, Packet: 3}, []byte{0x7e, 0x05, 0x7f, 0x03, 0xf7}},
+		{&SysExHandshake{DeviceID: 0x05, Type: HandshakeNAK, Packet: 0x7f}, []byte{0x7e, 0x05, 0x7e, 0x7f, 0xf7}},
+		{&SysExHandshake{DeviceID: 0x05, Type: HandshakeWait, Packet: 0}, []byte{0x7e, 0x05, 0x7c, 0x00, 0xf7}},
+	}
+	for _, test := range tests {
+		checkSysExRoundTrip(t, test.sysex, test.data)
+	}
+}
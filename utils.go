@@ -44,6 +44,16 @@ func tell(r io.ReadSeeker) int64 {
 	return pos
 }
 
+// Divides and rounds to the nearest integer, b must be positive
+func divRound(a, b int64) int64 {
+	a, b = 2*a+b, 2*b
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
 func dumpText(text string) string {
 	text = strconv.Quote(text)
 	return strings.Replace(text[1:len(text)-1], `\"`, `"`, -1)
@@ -0,0 +1,579 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// XFMeta is a Yamaha XF sequencer specific meta event of a known kind
+type XFMeta interface {
+	// Bytes of MetaEventSequencerSpecific.Data, starting with 43 7B
+	XFData() []byte
+	xfTag() string
+	encodeXMLAttr(el *etree.Element)
+}
+
+// 43 7B 00 58 46 Mj Mn S1 S0, Version is two ASCII digits such as "02"
+type XFVersion struct {
+	Version string
+	Status  uint16
+}
+
+// 43 7B 01 cr ct bn bt
+type XFChord struct {
+	Root     XFChordNote
+	Type     uint8
+	BassNote XFChordNote
+	BassType uint8
+}
+
+// 43 7B 02 rm, the lower nibble of Mark is the section and the upper nibble
+// is the variation
+type XFRehearsalMark struct {
+	Mark uint8
+}
+
+// The upper nibble is the accidental, 3 being natural, and the lower nibble is
+// the note name from 1 = C to 7 = B
+type XFChordNote uint8
+
+// Used for BassNote and BassType when the chord has no bass note
+const XFChordNone = 0x7f
+
+var xfChordTypeNames = [...]string{
+	"Maj", "Maj6", "Maj7", "Maj7(#11)", "Maj(9)", "Maj7(9)", "Maj6(9)", "aug",
+	"min", "min6", "min7", "min7b5", "min(9)", "min7(9)", "min7(11)", "minMaj7",
+	"minMaj7(9)", "dim", "dim7", "7th", "7sus4", "7b5", "7(9)", "7(#11)",
+	"7(13)", "7(b9)", "7(b13)", "7(#9)", "Maj7aug", "7aug", "1+8", "1+5",
+	"sus4", "1+2+5", "cc",
+}
+
+var xfRehearsalSectionNames = [...]string{
+	"Intro", "Ending", "Fill-in", "A", "B", "C", "D", "E", "F", "G", "H",
+}
+
+// Recognizes the data of a sequencer specific meta event.
+// Returns nil if the data is not a known XF message.
+func DecodeXFMeta(data []byte) (XFMeta, error) {
+	if len(data) < 3 || data[0] != 0x43 || data[1] != 0x7b {
+		return nil, nil
+	}
+	switch {
+	case data[2] == 0x00 && len(data) == 9 && data[3] == 'X' && data[4] == 'F':
+		return &XFVersion{
+			Version: string(data[5:7]),
+			Status:  uint16(data[7])<<8 | uint16(data[8]),
+		}, nil
+	case data[2] == 0x01 && len(data) == 7:
+		return &XFChord{
+			Root:     XFChordNote(data[3]),
+			Type:     data[4],
+			BassNote: XFChordNote(data[5]),
+			BassType: data[6],
+		}, nil
+	case data[2] == 0x02 && len(data) == 4:
+		return &XFRehearsalMark{Mark: data[3]}, nil
+	}
+	return nil, nil
+}
+
+func (ev *MetaEventSequencerSpecific) XF() (XFMeta, error) {
+	return DecodeXFMeta(ev.Data)
+}
+
+// Returns the known kind of a message, or nil if it can not be represented
+// exactly by one
+func decodeExactXFMeta(data []byte) XFMeta {
+	xf, err := DecodeXFMeta(data)
+	if err != nil || xf == nil || !bytes.Equal(xf.XFData(), data) {
+		return nil
+	}
+	return xf
+}
+
+func decodeXFMetaFromXML(el *etree.Element) (XFMeta, error) {
+	invalid := func(name string) error {
+		return newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, name, el.SelectAttrValue(name, "")))
+	}
+	switch el.Tag {
+	case "XFVersion":
+		version := el.SelectAttrValue("version", "")
+		if len(version) != 2 {
+			return nil, invalid("version")
+		}
+		status, err := strconv.ParseUint(el.SelectAttrValue("status", ""), 0, 16)
+		if err != nil {
+			return nil, invalid("status")
+		}
+		return &XFVersion{Version: version, Status: uint16(status)}, nil
+	case "XFChord":
+		xf := &XFChord{
+			BassNote: XFChordNone,
+			BassType: XFChordNone,
+		}
+		var err error
+		xf.Root, err = ParseXFChordNote(el.SelectAttrValue("root", ""))
+		if err != nil {
+			return nil, invalid("root")
+		}
+		xf.Type, err = parseXFChordType(el.SelectAttrValue("type", ""))
+		if err != nil {
+			return nil, invalid("type")
+		}
+		if bass := el.SelectAttr("bass"); bass != nil {
+			xf.BassNote, err = ParseXFChordNote(bass.Value)
+			if err != nil {
+				return nil, invalid("bass")
+			}
+		}
+		if bassType := el.SelectAttr("bass-type"); bassType != nil {
+			xf.BassType, err = parseXFChordType(bassType.Value)
+			if err != nil {
+				return nil, invalid("bass-type")
+			}
+		}
+		return xf, nil
+	case "XFRehearsalMark":
+		mark, err := ParseXFRehearsalMark(el.SelectAttrValue("mark", ""))
+		if err != nil {
+			return nil, invalid("mark")
+		}
+		return &XFRehearsalMark{Mark: mark}, nil
+	}
+	return nil, nil
+}
+
+func (xf *XFVersion) XFData() []byte {
+	version := []byte(xf.Version + "00")[:2]
+	return []byte{0x43, 0x7b, 0x00, 'X', 'F', version[0], version[1], uint8(xf.Status >> 8), uint8(xf.Status)}
+}
+
+func (xf *XFVersion) xfTag() string {
+	return "XFVersion"
+}
+
+func (xf *XFVersion) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("version", xf.Version)
+	el.CreateAttr("status", fmt.Sprintf("%#04x", xf.Status))
+}
+
+func (xf *XFChord) XFData() []byte {
+	return []byte{0x43, 0x7b, 0x01, uint8(xf.Root), xf.Type, uint8(xf.BassNote), xf.BassType}
+}
+
+func (xf *XFChord) xfTag() string {
+	return "XFChord"
+}
+
+func (xf *XFChord) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("root", xf.Root.String())
+	el.CreateAttr("type", xfChordTypeString(xf.Type))
+	if xf.BassNote != XFChordNone {
+		el.CreateAttr("bass", xf.BassNote.String())
+	}
+	if xf.BassType != XFChordNone {
+		el.CreateAttr("bass-type", xfChordTypeString(xf.BassType))
+	}
+}
+
+// Returns a chord symbol such as "C#min7/G"
+func (xf *XFChord) String() string {
+	name := xf.Root.String() + xfChordTypeString(xf.Type)
+	if xf.BassNote != XFChordNone {
+		name += "/" + xf.BassNote.String()
+		if xf.BassType != XFChordNone {
+			name += xfChordTypeString(xf.BassType)
+		}
+	}
+	return name
+}
+
+func (xf *XFRehearsalMark) XFData() []byte {
+	return []byte{0x43, 0x7b, 0x02, xf.Mark}
+}
+
+func (xf *XFRehearsalMark) xfTag() string {
+	return "XFRehearsalMark"
+}
+
+func (xf *XFRehearsalMark) encodeXMLAttr(el *etree.Element) {
+	el.CreateAttr("mark", xf.String())
+}
+
+// Returns a section name such as "A" or "Intro", followed by one apostrophe
+// for each variation
+func (xf *XFRehearsalMark) String() string {
+	section := int(xf.Mark & 0x0f)
+	variation := int(xf.Mark >> 4)
+	if section >= len(xfRehearsalSectionNames) || variation > 7 {
+		return fmt.Sprintf("%#02x", xf.Mark)
+	}
+	return xfRehearsalSectionNames[section] + strings.Repeat("'", variation)
+}
+
+func ParseXFRehearsalMark(str string) (uint8, error) {
+	name := strings.TrimRight(str, "'")
+	variation := len(str) - len(name)
+	for section, sectionName := range xfRehearsalSectionNames {
+		if sectionName == name && variation <= 7 {
+			return uint8(variation<<4 | section), nil
+		}
+	}
+	mark, err := strconv.ParseUint(str, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid XF rehearsal mark %q", str)
+	}
+	return uint8(mark), nil
+}
+
+func (note XFChordNote) String() string {
+	accidental, name := int(note>>4), int(note&0x0f)
+	if accidental > 6 || name < 1 || name > 7 {
+		return fmt.Sprintf("%#02x", uint8(note))
+	}
+	str := string("CDEFGAB"[name-1])
+	if accidental < 3 {
+		return str + strings.Repeat("b", 3-accidental)
+	}
+	return str + strings.Repeat("#", accidental-3)
+}
+
+func ParseXFChordNote(str string) (XFChordNote, error) {
+	if len(str) != 0 {
+		if name := strings.IndexByte("CDEFGAB", str[0]); name >= 0 {
+			flats, sharps := strings.Count(str[1:], "b"), strings.Count(str[1:], "#")
+			if flats+sharps == len(str)-1 && (flats == 0 || sharps == 0) && flats <= 3 && sharps <= 3 {
+				return XFChordNote((3-flats+sharps)<<4 | (name + 1)), nil
+			}
+		}
+	}
+	note, err := strconv.ParseUint(str, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid XF chord note %q", str)
+	}
+	return XFChordNote(note), nil
+}
+
+func xfChordTypeString(chordType uint8) string {
+	if int(chordType) < len(xfChordTypeNames) {
+		return xfChordTypeNames[chordType]
+	}
+	return fmt.Sprintf("%#02x", chordType)
+}
+
+func parseXFChordType(str string) (uint8, error) {
+	for chordType, name := range xfChordTypeNames {
+		if name == str {
+			return uint8(chordType), nil
+		}
+	}
+	chordType, err := strconv.ParseUint(str, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid XF chord type %q", str)
+	}
+	return uint8(chordType), nil
+}
+
+// Song information from an XFIH chunk, the fields of its "XFhd:" text
+type XFInfoHeader struct {
+	Date       string
+	Country    string
+	Category   string
+	Beat       string
+	Instrument string
+	Vocal      string
+	Composer   string
+	Lyricist   string
+	Arranger   string
+	Performer  string
+	Programmer string
+	Keywords   string
+}
+
+// Karaoke information from an XFKM chunk, the fields of its "XFln:" text
+type XFKaraokeHeader struct {
+	Language   string
+	SongName   string
+	Composer   string
+	Lyricist   string
+	Arranger   string
+	Performer  string
+	Programmer string
+}
+
+// A line of lyrics, split by the XF conventions of "/" for a new line, "<"
+// for a new page and "^" for a space
+type XFLyricLine struct {
+	AbsTick   int64
+	NewPage   bool
+	Text      string
+	Syllables []XFLyricSyllable
+}
+
+type XFLyricSyllable struct {
+	AbsTick int64
+	Text    string
+}
+
+type xfHeader interface {
+	Chunk() (*Chunk, error)
+	xfTag() string
+	fields() []*string
+	fieldAttrs() []string
+}
+
+var (
+	xfInfoHeaderAttrs    = [...]string{"date", "country", "category", "beat", "instrument", "vocal", "composer", "lyricist", "arranger", "performer", "programmer", "keywords"}
+	xfKaraokeHeaderAttrs = [...]string{"language", "song-name", "composer", "lyricist", "arranger", "performer", "programmer"}
+)
+
+func DecodeXFInfoHeader(chunk *Chunk) (*XFInfoHeader, bool) {
+	header := &XFInfoHeader{}
+	if string(chunk.Type[:]) != "XFIH" || !decodeXFHeaderFields(chunk.Data, "XFhd:", header.fields()) {
+		return nil, false
+	}
+	return header, true
+}
+
+func DecodeXFKaraokeHeader(chunk *Chunk) (*XFKaraokeHeader, bool) {
+	header := &XFKaraokeHeader{}
+	if string(chunk.Type[:]) != "XFKM" || !decodeXFHeaderFields(chunk.Data, "XFln:", header.fields()) {
+		return nil, false
+	}
+	return header, true
+}
+
+// Returns the song information of the first XFIH chunk
+func (seq *Sequence) XFInfoHeader() (*XFInfoHeader, bool) {
+	for _, chunk := range seq.Chunks {
+		if header, ok := DecodeXFInfoHeader(chunk); ok {
+			return header, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the karaoke information of the first XFKM chunk
+func (seq *Sequence) XFKaraokeHeader() (*XFKaraokeHeader, bool) {
+	for _, chunk := range seq.Chunks {
+		if header, ok := DecodeXFKaraokeHeader(chunk); ok {
+			return header, true
+		}
+	}
+	return nil, false
+}
+
+// The chunk holds a single text event, missing fields are left empty
+func decodeXFHeaderFields(data []byte, prefix string, fields []*string) bool {
+	r := bytes.NewReader(data)
+	status, channel := uint8(0), uint8(0)
+	for {
+		event, err := DecodeEventFromSMF(r, &status, &channel, IgnoreWarnings)
+		if err != nil {
+			return false
+		}
+		text, ok := event.(*MetaEventTextEvent)
+		if !ok || !strings.HasPrefix(text.Text, prefix) {
+			continue
+		}
+		for i, value := range strings.SplitN(text.Text[len(prefix):], ":", len(fields)) {
+			*fields[i] = value
+		}
+		return true
+	}
+}
+
+func encodeXFHeaderChunk(typ, prefix string, fields []*string) (*Chunk, error) {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = *field
+	}
+	event := &MetaEventTextEvent{
+		Text: prefix + strings.Join(values, ":"),
+	}
+	var buf bytes.Buffer
+	status, channel := uint8(0), uint8(0)
+	err := event.EncodeSMF(&buf, &status, &channel)
+	if err != nil {
+		return nil, err
+	}
+	chunk := &Chunk{
+		Data: buf.Bytes(),
+	}
+	copy(chunk.Type[:], typ)
+	return chunk, nil
+}
+
+// Returns the known kind of a chunk, or nil if it can not be represented
+// exactly by one
+func decodeExactXFHeader(chunk *Chunk) xfHeader {
+	var header xfHeader
+	if infoHeader, ok := DecodeXFInfoHeader(chunk); ok {
+		header = infoHeader
+	} else if karaokeHeader, ok := DecodeXFKaraokeHeader(chunk); ok {
+		header = karaokeHeader
+	} else {
+		return nil
+	}
+	encoded, err := header.Chunk()
+	if err != nil || !bytes.Equal(encoded.Data, chunk.Data) {
+		return nil
+	}
+	return header
+}
+
+func encodeXFHeaderXMLAttr(el *etree.Element, header xfHeader) {
+	attrs := header.fieldAttrs()
+	for i, field := range header.fields() {
+		el.CreateAttr(attrs[i], dumpText(*field))
+	}
+}
+
+func decodeXFHeaderFromXML(el *etree.Element) (*Chunk, error) {
+	var header xfHeader
+	switch el.Tag {
+	case "XFInfoHeader":
+		header = &XFInfoHeader{}
+	default:
+		header = &XFKaraokeHeader{}
+	}
+	attrs := header.fieldAttrs()
+	for i, field := range header.fields() {
+		var err error
+		*field, err = parseTextDump(el.SelectAttrValue(attrs[i], ""))
+		if err != nil {
+			return nil, newXMLDecodeError(el, fmt.Errorf("invalid attribute for %s tag: %s=%q", el.Tag, attrs[i], el.SelectAttrValue(attrs[i], "")))
+		}
+	}
+	chunk, err := header.Chunk()
+	if err != nil {
+		return nil, newXMLDecodeError(el, err)
+	}
+	return chunk, nil
+}
+
+func (header *XFInfoHeader) Chunk() (*Chunk, error) {
+	return encodeXFHeaderChunk("XFIH", "XFhd:", header.fields())
+}
+
+func (header *XFInfoHeader) xfTag() string {
+	return "XFInfoHeader"
+}
+
+func (header *XFInfoHeader) fields() []*string {
+	return []*string{&header.Date, &header.Country, &header.Category, &header.Beat, &header.Instrument, &header.Vocal, &header.Composer, &header.Lyricist, &header.Arranger, &header.Performer, &header.Programmer, &header.Keywords}
+}
+
+func (header *XFInfoHeader) fieldAttrs() []string {
+	return xfInfoHeaderAttrs[:]
+}
+
+func (header *XFKaraokeHeader) Chunk() (*Chunk, error) {
+	return encodeXFHeaderChunk("XFKM", "XFln:", header.fields())
+}
+
+func (header *XFKaraokeHeader) xfTag() string {
+	return "XFKaraokeHeader"
+}
+
+func (header *XFKaraokeHeader) fields() []*string {
+	return []*string{&header.Language, &header.SongName, &header.Composer, &header.Lyricist, &header.Arranger, &header.Performer, &header.Programmer}
+}
+
+func (header *XFKaraokeHeader) fieldAttrs() []string {
+	return xfKaraokeHeaderAttrs[:]
+}
+
+// Collects the lyric events of all tracks into lines.
+// Lyrics that are not valid UTF-8 are taken as Shift-JIS, the usual encoding
+// of Japanese XF files, so the second byte of a double-byte character is never
+// mistaken for a control character.
+func (seq *Sequence) XFLyrics() []*XFLyricLine {
+	var lines []*XFLyricLine
+	line := &XFLyricLine{}
+	endLine := func(newPage bool) {
+		if len(line.Syllables) != 0 {
+			lines = append(lines, line)
+			line = &XFLyricLine{}
+		}
+		line.NewPage = line.NewPage || newPage
+	}
+	it := newEventIterator(seq)
+	for {
+		_, event, absTick, ok := it.next()
+		if !ok {
+			break
+		}
+		lyric, ok := event.(*MetaEventLyric)
+		if !ok {
+			continue
+		}
+		var text []byte
+		addSyllable := func() {
+			if len(text) == 0 {
+				return
+			}
+			if len(line.Syllables) == 0 {
+				line.AbsTick = absTick
+			}
+			line.Syllables = append(line.Syllables, XFLyricSyllable{AbsTick: absTick, Text: string(text)})
+			line.Text += string(text)
+			text = text[:0]
+		}
+		sjis := !utf8.ValidString(lyric.Text)
+		for i := 0; i < len(lyric.Text); i++ {
+			c := lyric.Text[i]
+			if sjis && isShiftJISLeadByte(c) && i+1 < len(lyric.Text) {
+				// The trail byte may look like a control character
+				text = append(text, c, lyric.Text[i+1])
+				i++
+				continue
+			}
+			switch c {
+			case '/', '<':
+				addSyllable()
+				endLine(c == '<')
+			case '^':
+				text = append(text, ' ')
+			default:
+				text = append(text, c)
+			}
+		}
+		addSyllable()
+	}
+	endLine(false)
+	return lines
+}
+
+func isShiftJISLeadByte(c byte) bool {
+	return (c >= 0x81 && c <= 0x9f) || (c >= 0xe0 && c <= 0xfc)
+}
@@ -0,0 +1,64 @@
+/*
+  MIT License
+
+  Copyright (c) 2018 Star Brilliant
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software"), to deal
+  in the Software without restriction, including without limitation the rights
+  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+  copies of the Software, and to permit persons to whom the Software is
+  furnished to do so, subject to the following conditions:
+
+  The above copyright notice and this permission notice shall be included in
+  all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+  SOFTWARE.
+*/
+
+package midimark
+
+import "testing"
+
+func TestXFLyricsShiftJIS(t *testing.T) {
+	lyric := func(delta VLQ, text string) Event {
+		return &MetaEventLyric{EventCommon: EventCommon{DeltaTick: delta}, Text: text}
+	}
+	seq := &Sequence{
+		Header: &MThd{Format: 0, NTrks: 1, Division: 96},
+		Tracks: []*MTrk{{Events: []Event{
+			// "タ" and "表" end with the bytes of "^" and "\"
+			lyric(0, "\x83\x5e"),
+			lyric(10, "\x95\x5c/"),
+			lyric(10, "la^"),
+			lyric(10, "lá<"),
+			lyric(10, "end"),
+			&MetaEventEndOfTrack{},
+		}}},
+	}
+	lines := seq.XFLyrics()
+	want := []struct {
+		tick      int64
+		newPage   bool
+		text      string
+		syllables int
+	}{
+		{0, false, "\x83\x5e\x95\x5c", 2},
+		{20, false, "la lá", 2},
+		{40, true, "end", 1},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if line.AbsTick != want[i].tick || line.NewPage != want[i].newPage || line.Text != want[i].text || len(line.Syllables) != want[i].syllables {
+			t.Errorf("line %d: got %+v, want %+v", i, line, want[i])
+		}
+	}
+}